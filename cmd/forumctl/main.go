@@ -0,0 +1,173 @@
+// Command forumctl runs maintenance tasks against the Postgres database
+// configured with the same settings as the server.
+package main
+
+import (
+	"DbProjectForum/configs"
+	"DbProjectForum/internal/app/maintenance"
+	_maintenanceRepo "DbProjectForum/internal/app/maintenance/repository"
+	"DbProjectForum/internal/app/migrations"
+	"DbProjectForum/internal/app/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const usage = `usage: forumctl [flags] command [args]
+
+commands:
+  %s
+  recount                   recompute forum.posts, forum.threads and thread.votes
+  rebuild-users-forum       rebuild the users_forum participants table
+  rebuild-paths THREAD      rebuild post.path for the posts of thread THREAD
+  stats                     print row counts and the database size
+  export [FILE]             write the database as JSON lines to FILE or stdout
+  import [FILE]             load an export from FILE or stdin
+`
+
+func usageText() string {
+	return fmt.Sprintf(usage, strings.ReplaceAll(migrations.Usage, "\n", "\n  "))
+}
+
+type command func(ctx context.Context, repo maintenance.Repository, args []string) error
+
+var commands = map[string]command{
+	"recount":             recount,
+	"rebuild-users-forum": rebuildUsersForum,
+	"rebuild-paths":       rebuildPaths,
+	"stats":               stats,
+	"export":              export,
+	"import":              importData,
+}
+
+func main() {
+	config, args, err := configs.LoadCommand(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatal().Msgf(err.Error())
+	}
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usageText())
+		os.Exit(2)
+	}
+
+	if err := run(config, args); err != nil {
+		log.Fatal().Msgf("%s: %s", args[0], err)
+	}
+}
+
+func run(config configs.Config, args []string) error {
+	if args[0] == "migrate" {
+		conn, err := storage.Connect(config.Postgres)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return migrations.Command(conn, args[1:], os.Stdout)
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return errors.New("unknown command, " + usageText())
+	}
+
+	pool, err := storage.OpenPool(config.Postgres)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	return cmd(context.Background(), _maintenanceRepo.NewPostgresMaintenanceRepository(pool), args[1:])
+}
+
+func recount(ctx context.Context, repo maintenance.Repository, args []string) error {
+	forums, err := repo.RecountForums(ctx)
+	if err != nil {
+		return err
+	}
+	threads, err := repo.RecountThreadVotes(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("fixed counters of %d forums and votes of %d threads\n", forums, threads)
+	return nil
+}
+
+func rebuildUsersForum(ctx context.Context, repo maintenance.Repository, args []string) error {
+	changed, err := repo.RebuildUsersForum(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("added or removed %d forum participants\n", changed)
+	return nil
+}
+
+func rebuildPaths(ctx context.Context, repo maintenance.Repository, args []string) error {
+	if len(args) != 1 {
+		return errors.New("rebuild-paths needs a thread id")
+	}
+	threadID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid thread id %q", args[0])
+	}
+
+	changed, err := repo.RebuildPaths(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("fixed paths of %d posts\n", changed)
+	return nil
+}
+
+func stats(ctx context.Context, repo maintenance.Repository, args []string) error {
+	stats, err := repo.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stats)
+}
+
+func export(ctx context.Context, repo maintenance.Repository, args []string) error {
+	var w io.Writer = os.Stdout
+	if len(args) != 0 {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	count, err := repo.Export(ctx, w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d records\n", count)
+	return nil
+}
+
+func importData(ctx context.Context, repo maintenance.Repository, args []string) error {
+	var r io.Reader = os.Stdin
+	if len(args) != 0 {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	count, err := repo.Import(ctx, r)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d records\n", count)
+	return nil
+}
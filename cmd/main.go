@@ -2,71 +2,110 @@ package main
 
 import (
 	"DbProjectForum/configs"
-	_forumHandlers "DbProjectForum/internal/app/forum/delivery"
-	_forumRepo "DbProjectForum/internal/app/forum/repository"
-	_userHandlers "DbProjectForum/internal/app/user/delivery"
-	_userRepo "DbProjectForum/internal/app/user/repository"
-	"fmt"
-	"github.com/fasthttp/router"
-	"github.com/jackc/pgx"
+	"DbProjectForum/internal/app/maintenance/reconciler"
+	_maintenanceRepo "DbProjectForum/internal/app/maintenance/repository"
+	"DbProjectForum/internal/app/migrations"
+	_server "DbProjectForum/internal/app/server"
+	"DbProjectForum/internal/app/storage"
+	"DbProjectForum/internal/pkg/metrics"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-func applicationJSON(next fasthttp.RequestHandler) fasthttp.RequestHandler {
-	return func(ctx *fasthttp.RequestCtx) {
-		ctx.Response.Header.Set("Content-Type", "application/json")
-		next(ctx)
+func main() {
+	config, args, err := configs.LoadCommand(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatal().Msgf(err.Error())
 	}
-}
 
-//func loggingMiddleware(next http.Handler) http.Handler {
-//	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-//		rec := responses.StatusRecorder{ResponseWriter: w, Status: 200}
-//
-//		next.ServeHTTP(&rec, r)
-//		msg := fmt.Sprintf("URL: %s, METHOD: %s, Answer code: %d", r.RequestURI, r.Method, rec.Status)
-//		log.Info().Msgf(msg)
-//	})
-//}
+	level, _ := zerolog.ParseLevel(config.Log.Level)
+	zerolog.SetGlobalLevel(level)
 
-func main() {
-	r := router.New()
+	if len(args) != 0 {
+		if args[0] != "migrate" {
+			log.Fatal().Msgf("unknown command %q, the only command is:\n%s", args[0], migrations.Usage)
+		}
+		if err := migrate(config, args[1:]); err != nil {
+			log.Fatal().Msgf(err.Error())
+		}
+		return
+	}
 
-	//r.Use(applicationJSON)
-	//r.Use(loggingMiddleware)
+	repos, err := storage.Open(config)
+	if err != nil {
+		log.Fatal().Msgf(err.Error())
+	}
+	defer repos.Close()
 
-	connStr := fmt.Sprintf("user=%s password=%s dbname=%s sslmode=disable port=%s",
-		configs.PostgresPreferences.User,
-		configs.PostgresPreferences.Password,
-		configs.PostgresPreferences.DBName,
-		configs.PostgresPreferences.Port)
+	if repos.Pool != nil {
+		metrics.RegisterPool(repos.Pool)
+	}
 
-	pgxConn, err := pgx.ParseConnectionString(connStr)
-	if err != nil {
-		log.Error().Msgf(err.Error())
-		return
+	if repos.Pool != nil && config.Reconcile.Interval > 0 {
+		counters := reconciler.New(_maintenanceRepo.NewPostgresMaintenanceRepository(repos.Pool),
+			config.Reconcile.Interval)
+		counters.Start()
+		defer counters.Stop()
 	}
 
-	pgxConn.PreferSimpleProtocol = true
+	server := &fasthttp.Server{
+		Handler:            _server.NewHandler(repos, config),
+		MaxRequestBodySize: config.Server.MaxBodySize,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe(config.Server.Listen)
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
-	config := pgx.ConnPoolConfig{
-		ConnConfig:     pgxConn,
-		MaxConnections: 100,
-		AfterConnect:   nil,
-		AcquireTimeout: 0,
+	select {
+	case err := <-serverErr:
+		log.Error().Msgf(err.Error())
+	case sig := <-stop:
+		log.Info().Msgf("received %s, shutting down", sig)
+		if !shutdown(server, config.Server.ShutdownTimeout) {
+			// Handlers are still running, and the deferred repos.Close would
+			// close the pool under them. Exit and let the OS drop the
+			// connections instead.
+			os.Exit(1)
+		}
 	}
+}
 
-	connPool, err := pgx.NewConnPool(config)
+func migrate(config configs.Config, args []string) error {
+	conn, err := storage.Connect(config.Postgres)
 	if err != nil {
-		log.Error().Msgf(err.Error())
+		return err
 	}
+	defer conn.Close()
 
-	userRepo := _userRepo.NewPostgresCafeRepository(connPool)
-	forumRepo := _forumRepo.NewPostgresForumRepository(connPool, userRepo)
+	return migrations.Command(conn, args, os.Stdout)
+}
 
-	_userHandlers.NewUserHandler(r, userRepo, forumRepo)
-	_forumHandlers.NewForumHandler(r, forumRepo, userRepo)
+// shutdown stops accepting connections and waits for in-flight requests
+// to finish, giving up after timeout. It reports whether they finished.
+func shutdown(server *fasthttp.Server, timeout time.Duration) bool {
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Shutdown()
+	}()
 
-	log.Error().Msgf(fasthttp.ListenAndServe(":5000", applicationJSON(r.Handler)).Error())
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Error().Msgf(err.Error())
+		}
+		return true
+	case <-time.After(timeout):
+		log.Error().Msgf("in-flight requests did not finish in %s", timeout)
+		return false
+	}
 }
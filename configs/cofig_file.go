@@ -1,12 +1,316 @@
 package configs
 
-var PostgresPreferences postgresPreferencesStruct
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
-func init() {
-	PostgresPreferences = postgresPreferencesStruct{
-		User:     "docker",
-		Password: "docker",
-		DBName:   "docker",
-		Port:     "5432",
+const configPathEnv = "FORUM_CONFIG"
+
+// maxInsertBatch keeps multi-row post inserts, which take six parameters per
+// post, under the 65535 parameter limit of the protocol.
+const maxInsertBatch = 10000
+
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// setting binds one configuration value to its environment variable and command line flag.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"storage", "FORUM_STORAGE", "repository backend, postgres or memory", func(c *Config, v string) error {
+		c.Storage = v
+		return nil
+	}},
+	{"listen", "FORUM_LISTEN", "address the HTTP server listens on", func(c *Config, v string) error {
+		c.Server.Listen = v
+		return nil
+	}},
+	{"shutdown-timeout", "FORUM_SHUTDOWN_TIMEOUT", "how long to drain in-flight requests on shutdown", func(c *Config, v string) error {
+		timeout, err := time.ParseDuration(v)
+		c.Server.ShutdownTimeout = timeout
+		return err
+	}},
+	{"max-body-size", "FORUM_MAX_BODY_SIZE", "largest accepted request body in bytes", func(c *Config, v string) error {
+		size, err := strconv.Atoi(v)
+		c.Server.MaxBodySize = size
+		return err
+	}},
+	{"db-host", "FORUM_DB_HOST", "postgres host", func(c *Config, v string) error {
+		c.Postgres.Host = v
+		return nil
+	}},
+	{"db-port", "FORUM_DB_PORT", "postgres port", func(c *Config, v string) error {
+		port, err := strconv.Atoi(v)
+		c.Postgres.Port = port
+		return err
+	}},
+	{"db-user", "FORUM_DB_USER", "postgres user", func(c *Config, v string) error {
+		c.Postgres.User = v
+		return nil
+	}},
+	{"db-password", "FORUM_DB_PASSWORD", "postgres password", func(c *Config, v string) error {
+		c.Postgres.Password = v
+		return nil
+	}},
+	{"db-name", "FORUM_DB_NAME", "postgres database name", func(c *Config, v string) error {
+		c.Postgres.DBName = v
+		return nil
+	}},
+	{"db-sslmode", "FORUM_DB_SSLMODE", "postgres sslmode", func(c *Config, v string) error {
+		c.Postgres.SSLMode = v
+		return nil
+	}},
+	{"db-max-connections", "FORUM_DB_MAX_CONNECTIONS", "size of the postgres connection pool", func(c *Config, v string) error {
+		maxConnections, err := strconv.Atoi(v)
+		c.Postgres.MaxConnections = maxConnections
+		return err
+	}},
+	{"db-acquire-timeout", "FORUM_DB_ACQUIRE_TIMEOUT", "how long to wait for a free pool connection, 0 waits forever", func(c *Config, v string) error {
+		timeout, err := time.ParseDuration(v)
+		c.Postgres.AcquireTimeout = timeout
+		return err
+	}},
+	{"db-simple-protocol", "FORUM_DB_SIMPLE_PROTOCOL", "use the simple query protocol instead of prepared statements", func(c *Config, v string) error {
+		simple, err := strconv.ParseBool(v)
+		c.Postgres.SimpleProtocol = simple
+		return err
+	}},
+	{"db-copy-threshold", "FORUM_DB_COPY_THRESHOLD", "smallest batch of posts loaded with COPY", func(c *Config, v string) error {
+		threshold, err := strconv.Atoi(v)
+		c.Postgres.CopyThreshold = threshold
+		return err
+	}},
+	{"db-migrate", "FORUM_DB_MIGRATE", "apply pending schema migrations on start", func(c *Config, v string) error {
+		migrate, err := strconv.ParseBool(v)
+		c.Postgres.Migrate = migrate
+		return err
+	}},
+	{"log-level", "FORUM_LOG_LEVEL", "minimal level of log messages", func(c *Config, v string) error {
+		c.Log.Level = v
+		return nil
+	}},
+	{"slow-query", "FORUM_SLOW_QUERY", "repository calls slower than this are logged as warnings", func(c *Config, v string) error {
+		threshold, err := time.ParseDuration(v)
+		c.Log.SlowQuery = threshold
+		return err
+	}},
+	{"reconcile-interval", "FORUM_RECONCILE_INTERVAL", "how often to fix drifted forum and thread counters, 0 disables", func(c *Config, v string) error {
+		interval, err := time.ParseDuration(v)
+		c.Reconcile.Interval = interval
+		return err
+	}},
+	{"session-ttl", "FORUM_SESSION_TTL", "how long a login session stays valid", func(c *Config, v string) error {
+		ttl, err := time.ParseDuration(v)
+		c.Auth.SessionTTL = ttl
+		return err
+	}},
+	{"auth-required", "FORUM_AUTH_REQUIRED", "reject anonymous posting, voting and editing", func(c *Config, v string) error {
+		required, err := strconv.ParseBool(v)
+		c.Auth.Required = required
+		return err
+	}},
+	{"admins", "FORUM_ADMINS", "comma separated nicknames that are always admins", func(c *Config, v string) error {
+		c.Auth.Admins = nil
+		for _, nickname := range strings.Split(v, ",") {
+			if nickname = strings.TrimSpace(nickname); nickname != "" {
+				c.Auth.Admins = append(c.Auth.Admins, nickname)
+			}
+		}
+		return nil
+	}},
+}
+
+func Default() Config {
+	return Config{
+		Storage: StoragePostgres,
+		Server: ServerConfig{
+			Listen:          ":5000",
+			ShutdownTimeout: 10 * time.Second,
+			MaxBodySize:     64 << 20,
+		},
+		Postgres: PostgresConfig{
+			Host:           "localhost",
+			Port:           5432,
+			User:           "docker",
+			Password:       "docker",
+			DBName:         "docker",
+			SSLMode:        "disable",
+			MaxConnections: 100,
+			CopyThreshold:  1000,
+			Migrate:        true,
+		},
+		Log: LogConfig{
+			Level:     "info",
+			SlowQuery: 200 * time.Millisecond,
+		},
+		Reconcile: ReconcileConfig{
+			Interval: 5 * time.Minute,
+		},
+		Auth: AuthConfig{
+			SessionTTL: 7 * 24 * time.Hour,
+		},
 	}
 }
+
+// Load builds the configuration from defaults, an optional YAML file, environment
+// variables and command line flags, each source overriding the previous one.
+func Load(name string, args []string) (Config, error) {
+	config, _, err := LoadCommand(name, args)
+	return config, err
+}
+
+// LoadCommand is Load for binaries with subcommands: it also returns the
+// arguments left after the flags.
+func LoadCommand(name string, args []string) (Config, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(configPathEnv), "path to a YAML config file")
+	for _, s := range settings {
+		fs.String(s.flag, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	config := Default()
+	if *configPath != "" {
+		if err := readFile(*configPath, &config); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err := s.set(&config, value); err != nil {
+			return Config{}, nil, fmt.Errorf("env %s: %w", s.env, err)
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag != f.Name || flagErr != nil {
+				continue
+			}
+			if err := s.set(&config, f.Value.String()); err != nil {
+				flagErr = fmt.Errorf("flag -%s: %w", s.flag, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, nil, flagErr
+	}
+
+	return config, fs.Args(), config.Validate()
+}
+
+func readFile(path string, config *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.Storage != StoragePostgres && c.Storage != StorageMemory {
+		problems = append(problems, fmt.Sprintf("storage %q is not supported", c.Storage))
+	}
+	if c.Server.Listen == "" {
+		problems = append(problems, "server.listen must not be empty")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, "server.shutdown_timeout must be positive")
+	}
+	if c.Server.MaxBodySize <= 0 {
+		problems = append(problems, "server.max_body_size must be positive")
+	}
+	if c.Postgres.Host == "" {
+		problems = append(problems, "postgres.host must not be empty")
+	}
+	if c.Postgres.Port <= 0 || c.Postgres.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("postgres.port %d is out of range", c.Postgres.Port))
+	}
+	if c.Postgres.User == "" {
+		problems = append(problems, "postgres.user must not be empty")
+	}
+	if c.Postgres.DBName == "" {
+		problems = append(problems, "postgres.dbname must not be empty")
+	}
+	if !sslModes[c.Postgres.SSLMode] {
+		problems = append(problems, fmt.Sprintf("postgres.sslmode %q is not supported", c.Postgres.SSLMode))
+	}
+	if c.Postgres.MaxConnections < 2 {
+		problems = append(problems, "postgres.max_connections must be at least 2")
+	}
+	if c.Postgres.AcquireTimeout < 0 {
+		problems = append(problems, "postgres.acquire_timeout must not be negative")
+	}
+	if c.Postgres.CopyThreshold < 1 || c.Postgres.CopyThreshold > maxInsertBatch {
+		problems = append(problems, fmt.Sprintf("postgres.copy_threshold must be between 1 and %d", maxInsertBatch))
+	}
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("log.level %q is not supported", c.Log.Level))
+	}
+	if c.Log.SlowQuery <= 0 {
+		problems = append(problems, "log.slow_query must be positive")
+	}
+
+	if c.Reconcile.Interval < 0 {
+		problems = append(problems, "reconcile.interval must not be negative")
+	}
+	if c.Auth.SessionTTL <= 0 {
+		problems = append(problems, "auth.session_ttl must be positive")
+	}
+
+	if len(problems) != 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ConnString renders the settings as a libpq key/value string. Values are
+// quoted so that spaces, quotes and backslashes in them survive parsing.
+func (p PostgresConfig) ConnString() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteConnValue(p.Host), p.Port, quoteConnValue(p.User), quoteConnValue(p.Password),
+		quoteConnValue(p.DBName), quoteConnValue(p.SSLMode))
+}
+
+func quoteConnValue(value string) string {
+	return "'" + connValueEscaper.Replace(value) + "'"
+}
+
+var connValueEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
@@ -1,8 +1,59 @@
 package configs
 
-type postgresPreferencesStruct struct {
-	User     string
-	Password string
-	DBName   string
-	Port     string
+import "time"
+
+type Config struct {
+	// Storage selects the repository backend: "postgres" or "memory".
+	Storage   string          `yaml:"storage"`
+	Server    ServerConfig    `yaml:"server"`
+	Postgres  PostgresConfig  `yaml:"postgres"`
+	Log       LogConfig       `yaml:"log"`
+	Reconcile ReconcileConfig `yaml:"reconcile"`
+	Auth      AuthConfig      `yaml:"auth"`
+}
+
+type ServerConfig struct {
+	Listen          string        `yaml:"listen"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	MaxBodySize     int           `yaml:"max_body_size"`
+}
+
+type PostgresConfig struct {
+	Host           string        `yaml:"host"`
+	Port           int           `yaml:"port"`
+	User           string        `yaml:"user"`
+	Password       string        `yaml:"password"`
+	DBName         string        `yaml:"dbname"`
+	SSLMode        string        `yaml:"sslmode"`
+	MaxConnections int           `yaml:"max_connections"`
+	AcquireTimeout time.Duration `yaml:"acquire_timeout"`
+	// SimpleProtocol disables prepared statements, as required behind pgbouncer
+	// in transaction pooling mode.
+	SimpleProtocol bool `yaml:"simple_protocol"`
+	// CopyThreshold is the smallest batch of posts that is loaded with COPY
+	// instead of a multi-row INSERT.
+	CopyThreshold int `yaml:"copy_threshold"`
+	// Migrate applies pending schema migrations when the server starts.
+	Migrate bool `yaml:"migrate"`
+}
+
+type LogConfig struct {
+	Level     string        `yaml:"level"`
+	SlowQuery time.Duration `yaml:"slow_query"`
+}
+
+type ReconcileConfig struct {
+	// Interval between passes of the counter reconciler, zero disables it.
+	Interval time.Duration `yaml:"interval"`
+}
+
+type AuthConfig struct {
+	// SessionTTL is how long a session token issued at login stays valid.
+	SessionTTL time.Duration `yaml:"session_ttl"`
+	// Required makes forums, threads, posts and votes writable only with a
+	// session. Anonymous writes act as the user named in the body otherwise.
+	Required bool `yaml:"required"`
+	// Admins are nicknames that are admins whatever role is stored for
+	// them, which is how the first admin gets in.
+	Admins []string `yaml:"admins"`
 }
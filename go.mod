@@ -1,19 +1,22 @@
 module DbProjectForum
 
-go 1.14
+go 1.16
 
 require (
 	github.com/cockroachdb/apd v1.1.0 // indirect
 	github.com/fasthttp/router v1.0.4
 	github.com/go-openapi/strfmt v0.19.5
-	github.com/gofrs/uuid v3.3.0+incompatible // indirect
+	github.com/gofrs/uuid v3.3.0+incompatible
 	github.com/jackc/fake v0.0.0-20150926172116-812a484cc733 // indirect
 	github.com/jackc/pgtype v1.3.0
 	github.com/jackc/pgx v3.6.2+incompatible
 	github.com/jmoiron/sqlx v1.2.0 // indirect
 	github.com/lib/pq v1.5.1 // indirect
+	github.com/prometheus/client_golang v1.7.1
 	github.com/rs/zerolog v1.18.0
 	github.com/shopspring/decimal v1.2.0 // indirect
 	github.com/valyala/fasthttp v1.12.0
+	golang.org/x/crypto v0.0.0-20190820162420-60c769a6c586
 	google.golang.org/appengine v1.6.6 // indirect
+	gopkg.in/yaml.v2 v2.3.0
 )
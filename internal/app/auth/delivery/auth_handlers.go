@@ -0,0 +1,204 @@
+package delivery
+
+import (
+	"DbProjectForum/internal/app/auth"
+	"DbProjectForum/internal/app/auth/models"
+	"DbProjectForum/internal/app/user"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/identity"
+	"DbProjectForum/internal/pkg/responses"
+	"encoding/json"
+	"errors"
+	"github.com/fasthttp/router"
+	"github.com/go-openapi/strfmt"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"time"
+)
+
+type authHandler struct {
+	authRepo   auth.Repository
+	userRepo   user.Repository
+	sessionTTL time.Duration
+}
+
+func NewAuthHandler(r *router.Router, ar auth.Repository, ur user.Repository, sessionTTL time.Duration) {
+	handler := authHandler{
+		authRepo:   ar,
+		userRepo:   ur,
+		sessionTTL: sessionTTL,
+	}
+
+	r.POST("/api/auth/login", handler.Login)
+	r.POST("/api/auth/logout", handler.Logout)
+
+	r.POST("/api/auth/tokens", handler.CreateAPIToken)
+	r.GET("/api/auth/tokens", handler.GetAPITokens)
+	r.DELETE("/api/auth/tokens/{id:[0-9]+}", handler.DeleteAPIToken)
+}
+
+func (a *authHandler) Login(ctx *fasthttp.RequestCtx) {
+	var credentials models.Credentials
+	if err := json.Unmarshal(ctx.PostBody(), &credentials); err != nil {
+		responses.SendError(apperrors.Validation("Invalid credentials: %s", err), ctx)
+		return
+	}
+
+	userObj, err := a.userRepo.GetByNick(ctx, credentials.Nickname)
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		responses.SendError(err, ctx)
+		return
+	}
+	// Unknown nicknames are checked against an empty hash, which takes as long
+	// as a wrong password would.
+	var passwordHash string
+	if err == nil {
+		passwordHash = userObj.PasswordHash
+	}
+	if !auth.CheckPassword(passwordHash, credentials.Password) {
+		responses.SendError(apperrors.Unauthorized("Wrong nickname or password"), ctx)
+		return
+	}
+
+	token, tokenHash, err := auth.NewToken()
+	if err != nil {
+		responses.SendServerError(err.Error(), ctx)
+		return
+	}
+	now := time.Now()
+	session := models.Session{
+		TokenHash: tokenHash,
+		Nickname:  userObj.Nickname,
+		Created:   now,
+		Expires:   now.Add(a.sessionTTL),
+	}
+	if err := a.authRepo.CreateSession(ctx, session); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	responses.SendResponseOK(models.Login{
+		Nickname: session.Nickname,
+		Token:    token,
+		Expires:  strfmt.DateTime(session.Expires.UTC()).String(),
+	}, ctx)
+}
+
+// Logout ends the session whose token authenticated the request.
+func (a *authHandler) Logout(ctx *fasthttp.RequestCtx) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		responses.SendError(apperrors.Unauthorized("Missing session token"), ctx)
+		return
+	}
+
+	err := a.authRepo.DeleteSession(ctx, auth.HashToken(token))
+	if errors.Is(err, apperrors.ErrNotFound) {
+		err = apperrors.Unauthorized("Invalid or expired session token")
+	}
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	responses.SendResponseOK("", ctx)
+}
+
+// loggedIn returns the nickname of the user behind the request, who manages
+// their own API tokens.
+func loggedIn(ctx *fasthttp.RequestCtx) (string, error) {
+	nickname := identity.FromContext(ctx)
+	if nickname == "" {
+		return "", apperrors.Unauthorized("Log in to manage API tokens")
+	}
+	return nickname, nil
+}
+
+// CreateAPIToken issues a token for the logged in user. Scopes are required
+// and repeated ones are dropped.
+func (a *authHandler) CreateAPIToken(ctx *fasthttp.RequestCtx) {
+	nickname, err := loggedIn(ctx)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	var request models.NewAPIToken
+	if err := json.Unmarshal(ctx.PostBody(), &request); err != nil {
+		responses.SendError(apperrors.Validation("Invalid API token: %s", err), ctx)
+		return
+	}
+	if request.Name == "" {
+		responses.SendError(apperrors.Validation("API token needs a name"), ctx)
+		return
+	}
+	if len(request.Scopes) == 0 {
+		responses.SendError(apperrors.Validation("API token needs at least one scope"), ctx)
+		return
+	}
+	scopes := make([]string, 0, len(request.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range request.Scopes {
+		if !auth.ValidScope(scope) {
+			responses.SendError(apperrors.Validation("Unknown scope: %s", scope), ctx)
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	token, tokenHash, err := auth.NewAPIToken()
+	if err != nil {
+		responses.SendServerError(err.Error(), ctx)
+		return
+	}
+	apiToken, err := a.authRepo.CreateAPIToken(ctx, models.APIToken{
+		TokenHash: tokenHash,
+		Nickname:  nickname,
+		Name:      request.Name,
+		Scopes:    scopes,
+	})
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	responses.SendResponse(201, models.CreatedAPIToken{APIToken: apiToken, Token: token}, ctx)
+}
+
+func (a *authHandler) GetAPITokens(ctx *fasthttp.RequestCtx) {
+	nickname, err := loggedIn(ctx)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	tokens, err := a.authRepo.GetAPITokens(ctx, nickname)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	responses.SendResponseOK(tokens, ctx)
+}
+
+func (a *authHandler) DeleteAPIToken(ctx *fasthttp.RequestCtx) {
+	nickname, err := loggedIn(ctx)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	idStr, _ := ctx.UserValue("id").(string)
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		responses.SendError(apperrors.Validation("Invalid API token id: %s", idStr), ctx)
+		return
+	}
+
+	if err := a.authRepo.DeleteAPIToken(ctx, nickname, id); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	responses.SendResponseOK("", ctx)
+}
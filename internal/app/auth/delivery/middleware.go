@@ -0,0 +1,95 @@
+package delivery
+
+import (
+	"DbProjectForum/internal/app/auth"
+	userModels "DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/identity"
+	"DbProjectForum/internal/pkg/middleware"
+	"DbProjectForum/internal/pkg/responses"
+	"bytes"
+	"errors"
+	"github.com/valyala/fasthttp"
+	"strings"
+)
+
+var bearerPrefix = []byte("Bearer ")
+
+// bearerToken extracts the token of an "Authorization: Bearer <token>" header.
+func bearerToken(ctx *fasthttp.RequestCtx) (string, bool) {
+	header := ctx.Request.Header.Peek(fasthttp.HeaderAuthorization)
+	if !bytes.HasPrefix(header, bearerPrefix) || len(header) == len(bearerPrefix) {
+		return "", false
+	}
+	return string(header[len(bearerPrefix):]), true
+}
+
+// authenticate returns the user behind a session or API token and their
+// role. An API token must also have the scope the request needs.
+func authenticate(ctx *fasthttp.RequestCtx, authRepo auth.Repository, token string) (string, userModels.Role, error) {
+	if !strings.HasPrefix(token, auth.APITokenPrefix) {
+		session, err := authRepo.GetSession(ctx, auth.HashToken(token))
+		if errors.Is(err, apperrors.ErrNotFound) {
+			err = apperrors.Unauthorized("Invalid or expired session token")
+		}
+		return session.Nickname, session.Role, err
+	}
+
+	apiToken, err := authRepo.UseAPIToken(ctx, auth.HashToken(token))
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return "", "", apperrors.Unauthorized("Invalid or revoked API token")
+	}
+	if err != nil {
+		return "", "", err
+	}
+	scope := auth.RequiredScope(string(ctx.Method()), string(ctx.Path()))
+	if !auth.Allows(apiToken.Scopes, scope) {
+		return "", "", apperrors.Forbidden("API token lacks the %s scope", scope)
+	}
+	return apiToken.Nickname, apiToken.Role, nil
+}
+
+// Authenticate resolves the session or API token of the request and stores
+// the nickname and role of its user under identity.Key and identity.RoleKey.
+// Requests without an Authorization header pass through anonymously; a
+// header that doesn't name a valid token is rejected with 401 rather than
+// being ignored. Users listed in admins are admins; banned users may only
+// read and log out.
+func Authenticate(authRepo auth.Repository, admins []string) middleware.Middleware {
+	isAdmin := make(map[string]bool, len(admins))
+	for _, nickname := range admins {
+		isAdmin[strings.ToLower(nickname)] = true
+	}
+
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			if len(ctx.Request.Header.Peek(fasthttp.HeaderAuthorization)) == 0 {
+				next(ctx)
+				return
+			}
+
+			token, ok := bearerToken(ctx)
+			if !ok {
+				responses.SendError(apperrors.Unauthorized("Malformed Authorization header"), ctx)
+				return
+			}
+			nickname, role, err := authenticate(ctx, authRepo, token)
+			if err != nil {
+				responses.SendError(err, ctx)
+				return
+			}
+			if isAdmin[strings.ToLower(nickname)] {
+				role = userModels.RoleAdmin
+			}
+			if role == userModels.RoleBanned && !ctx.IsGet() && !ctx.IsHead() &&
+				string(ctx.Path()) != "/api/auth/logout" {
+				responses.SendError(apperrors.Forbidden("User %s is banned", nickname), ctx)
+				return
+			}
+
+			ctx.SetUserValue(identity.Key, nickname)
+			ctx.SetUserValue(identity.RoleKey, role)
+			next(ctx)
+		}
+	}
+}
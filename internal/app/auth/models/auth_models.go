@@ -0,0 +1,54 @@
+package models
+
+import (
+	userModels "DbProjectForum/internal/app/user/models"
+	"time"
+)
+
+// Session is a login session. Only the hash of its token is stored.
+type Session struct {
+	TokenHash string
+	Nickname  string
+	Created   time.Time
+	Expires   time.Time
+	// Role is the current site role of the user, filled in by GetSession.
+	Role userModels.Role
+}
+
+type Credentials struct {
+	Nickname string `json:"nickname"`
+	Password string `json:"password"`
+}
+
+// Login is the answer to a successful login. Token is shown only once.
+type Login struct {
+	Nickname string `json:"nickname"`
+	Token    string `json:"token"`
+	Expires  string `json:"expires"`
+}
+
+// APIToken is a long-lived token for bots and integrations, limited to its
+// scopes. Like with sessions, only the hash of the token is stored.
+type APIToken struct {
+	ID        int64      `json:"id"`
+	TokenHash string     `json:"-"`
+	Nickname  string     `json:"nickname"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	Created   time.Time  `json:"created"`
+	LastUsed  *time.Time `json:"lastUsed"`
+	// Role is the current site role of the user, filled in by UseAPIToken.
+	Role userModels.Role `json:"-"`
+}
+
+type NewAPIToken struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreatedAPIToken is the answer to creating an API token. Token is shown
+// only once.
+type CreatedAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"DbProjectForum/internal/app/auth/models"
+	"context"
+)
+
+type Repository interface {
+	CreateSession(ctx context.Context, session models.Session) error
+	// GetSession finds a session that has not expired by the hash of its token.
+	GetSession(ctx context.Context, tokenHash string) (models.Session, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+
+	// CreateAPIToken stores token and returns it with its id and creation time.
+	CreateAPIToken(ctx context.Context, token models.APIToken) (models.APIToken, error)
+	GetAPITokens(ctx context.Context, nickname string) ([]models.APIToken, error)
+	// UseAPIToken finds an API token by its hash and records that it was used.
+	UseAPIToken(ctx context.Context, tokenHash string) (models.APIToken, error)
+	DeleteAPIToken(ctx context.Context, nickname string, id int64) error
+}
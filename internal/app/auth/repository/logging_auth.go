@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/auth"
+	"DbProjectForum/internal/app/auth/models"
+	"DbProjectForum/internal/pkg/requestid"
+	"context"
+	"time"
+)
+
+// loggingAuthRepository logs every repository call with the ID of the HTTP request
+// that caused it and warns about calls slower than slowQuery.
+type loggingAuthRepository struct {
+	next      auth.Repository
+	slowQuery time.Duration
+}
+
+func NewLoggingAuthRepository(next auth.Repository, slowQuery time.Duration) auth.Repository {
+	return &loggingAuthRepository{
+		next:      next,
+		slowQuery: slowQuery,
+	}
+}
+
+func (l *loggingAuthRepository) observe(ctx context.Context, method string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	logger := requestid.Logger(ctx)
+
+	event := logger.Debug()
+	if elapsed >= l.slowQuery {
+		event = logger.Warn()
+	}
+	if err != nil {
+		event = event.Str("error", err.Error())
+	}
+	event.Str("repository", "auth").
+		Str("method", method).
+		Dur("latency", elapsed).
+		Msg("repository call")
+}
+
+func (l *loggingAuthRepository) CreateSession(ctx context.Context, session models.Session) error {
+	start := time.Now()
+	err := l.next.CreateSession(ctx, session)
+	l.observe(ctx, "CreateSession", start, err)
+	return err
+}
+
+func (l *loggingAuthRepository) GetSession(ctx context.Context, tokenHash string) (models.Session, error) {
+	start := time.Now()
+	result, err := l.next.GetSession(ctx, tokenHash)
+	l.observe(ctx, "GetSession", start, err)
+	return result, err
+}
+
+func (l *loggingAuthRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	start := time.Now()
+	err := l.next.DeleteSession(ctx, tokenHash)
+	l.observe(ctx, "DeleteSession", start, err)
+	return err
+}
+
+func (l *loggingAuthRepository) CreateAPIToken(ctx context.Context, token models.APIToken) (models.APIToken, error) {
+	start := time.Now()
+	result, err := l.next.CreateAPIToken(ctx, token)
+	l.observe(ctx, "CreateAPIToken", start, err)
+	return result, err
+}
+
+func (l *loggingAuthRepository) GetAPITokens(ctx context.Context, nickname string) ([]models.APIToken, error) {
+	start := time.Now()
+	result, err := l.next.GetAPITokens(ctx, nickname)
+	l.observe(ctx, "GetAPITokens", start, err)
+	return result, err
+}
+
+func (l *loggingAuthRepository) UseAPIToken(ctx context.Context, tokenHash string) (models.APIToken, error) {
+	start := time.Now()
+	result, err := l.next.UseAPIToken(ctx, tokenHash)
+	l.observe(ctx, "UseAPIToken", start, err)
+	return result, err
+}
+
+func (l *loggingAuthRepository) DeleteAPIToken(ctx context.Context, nickname string, id int64) error {
+	start := time.Now()
+	err := l.next.DeleteAPIToken(ctx, nickname, id)
+	l.observe(ctx, "DeleteAPIToken", start, err)
+	return err
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/auth"
+	"DbProjectForum/internal/app/auth/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/memstore"
+	"context"
+	"sort"
+	"time"
+)
+
+type memoryAuthRepository struct {
+	store *memstore.Store
+}
+
+func NewMemoryAuthRepository(store *memstore.Store) auth.Repository {
+	return &memoryAuthRepository{store: store}
+}
+
+func (m *memoryAuthRepository) CreateSession(ctx context.Context, session models.Session) error {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	userObj, ok := m.store.Users[memstore.Key(session.Nickname)]
+	if !ok {
+		return apperrors.NotFound("Can't find user by nickname: %s", session.Nickname)
+	}
+	for tokenHash, stored := range m.store.Sessions {
+		if memstore.Key(stored.Nickname) == memstore.Key(session.Nickname) && !stored.Expires.After(time.Now()) {
+			delete(m.store.Sessions, tokenHash)
+		}
+	}
+
+	session.Nickname = userObj.Nickname
+	m.store.Sessions[session.TokenHash] = session
+	return nil
+}
+
+func (m *memoryAuthRepository) GetSession(ctx context.Context, tokenHash string) (models.Session, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	session, ok := m.store.Sessions[tokenHash]
+	if !ok || !session.Expires.After(time.Now()) {
+		return models.Session{}, apperrors.NotFound("Session not found")
+	}
+	session.Role = m.store.Users[memstore.Key(session.Nickname)].Role
+	return session, nil
+}
+
+func (m *memoryAuthRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	if _, ok := m.store.Sessions[tokenHash]; !ok {
+		return apperrors.NotFound("Session not found")
+	}
+	delete(m.store.Sessions, tokenHash)
+	return nil
+}
+
+func (m *memoryAuthRepository) CreateAPIToken(ctx context.Context, token models.APIToken) (models.APIToken, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	userObj, ok := m.store.Users[memstore.Key(token.Nickname)]
+	if !ok {
+		return models.APIToken{}, apperrors.NotFound("Can't find user by nickname: %s", token.Nickname)
+	}
+	if _, ok := m.store.APITokens[token.TokenHash]; ok {
+		return models.APIToken{}, apperrors.Conflict("API token already exists")
+	}
+
+	m.store.APITokenID++
+	token.ID = m.store.APITokenID
+	token.Nickname = userObj.Nickname
+	token.Created = time.Now()
+	token.LastUsed = nil
+	m.store.APITokens[token.TokenHash] = token
+	return token, nil
+}
+
+func (m *memoryAuthRepository) GetAPITokens(ctx context.Context, nickname string) ([]models.APIToken, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	tokens := make([]models.APIToken, 0)
+	for _, token := range m.store.APITokens {
+		if memstore.Key(token.Nickname) == memstore.Key(nickname) {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID < tokens[j].ID
+	})
+	return tokens, nil
+}
+
+func (m *memoryAuthRepository) UseAPIToken(ctx context.Context, tokenHash string) (models.APIToken, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	token, ok := m.store.APITokens[tokenHash]
+	if !ok {
+		return models.APIToken{}, apperrors.NotFound("API token not found")
+	}
+	now := time.Now()
+	token.LastUsed = &now
+	m.store.APITokens[tokenHash] = token
+	token.Role = m.store.Users[memstore.Key(token.Nickname)].Role
+	return token, nil
+}
+
+func (m *memoryAuthRepository) DeleteAPIToken(ctx context.Context, nickname string, id int64) error {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	for tokenHash, token := range m.store.APITokens {
+		if token.ID == id && memstore.Key(token.Nickname) == memstore.Key(nickname) {
+			delete(m.store.APITokens, tokenHash)
+			return nil
+		}
+	}
+	return apperrors.NotFound("Can't find API token: %d", id)
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/auth"
+	"DbProjectForum/internal/app/auth/models"
+	"DbProjectForum/internal/pkg/metrics"
+	"context"
+	"time"
+)
+
+// metricsAuthRepository records the latency of every repository call.
+type metricsAuthRepository struct {
+	next auth.Repository
+}
+
+func NewMetricsAuthRepository(next auth.Repository) auth.Repository {
+	return &metricsAuthRepository{next: next}
+}
+
+func (m *metricsAuthRepository) observe(method string, start time.Time, err error) {
+	metrics.RepositoryDuration.WithLabelValues("auth", method, metrics.Outcome(err)).
+		Observe(time.Since(start).Seconds())
+}
+
+func (m *metricsAuthRepository) CreateSession(ctx context.Context, session models.Session) error {
+	start := time.Now()
+	err := m.next.CreateSession(ctx, session)
+	m.observe("CreateSession", start, err)
+	return err
+}
+
+func (m *metricsAuthRepository) GetSession(ctx context.Context, tokenHash string) (models.Session, error) {
+	start := time.Now()
+	result, err := m.next.GetSession(ctx, tokenHash)
+	m.observe("GetSession", start, err)
+	return result, err
+}
+
+func (m *metricsAuthRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	start := time.Now()
+	err := m.next.DeleteSession(ctx, tokenHash)
+	m.observe("DeleteSession", start, err)
+	return err
+}
+
+func (m *metricsAuthRepository) CreateAPIToken(ctx context.Context, token models.APIToken) (models.APIToken, error) {
+	start := time.Now()
+	result, err := m.next.CreateAPIToken(ctx, token)
+	m.observe("CreateAPIToken", start, err)
+	return result, err
+}
+
+func (m *metricsAuthRepository) GetAPITokens(ctx context.Context, nickname string) ([]models.APIToken, error) {
+	start := time.Now()
+	result, err := m.next.GetAPITokens(ctx, nickname)
+	m.observe("GetAPITokens", start, err)
+	return result, err
+}
+
+func (m *metricsAuthRepository) UseAPIToken(ctx context.Context, tokenHash string) (models.APIToken, error) {
+	start := time.Now()
+	result, err := m.next.UseAPIToken(ctx, tokenHash)
+	m.observe("UseAPIToken", start, err)
+	return result, err
+}
+
+func (m *metricsAuthRepository) DeleteAPIToken(ctx context.Context, nickname string, id int64) error {
+	start := time.Now()
+	err := m.next.DeleteAPIToken(ctx, nickname, id)
+	m.observe("DeleteAPIToken", start, err)
+	return err
+}
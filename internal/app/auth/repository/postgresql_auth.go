@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/auth"
+	"DbProjectForum/internal/app/auth/models"
+	userModels "DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+)
+
+type postgresAuthRepository struct {
+	conn     *pgx.ConnPool
+	prepared bool
+}
+
+// NewPostgresAuthRepository creates the auth repository. When prepared is
+// set, queries run through the statements registered by PrepareStatements.
+func NewPostgresAuthRepository(conn *pgx.ConnPool, prepared bool) auth.Repository {
+	return &postgresAuthRepository{conn: conn, prepared: prepared}
+}
+
+func (p *postgresAuthRepository) statement(name string) string {
+	if p.prepared {
+		return name
+	}
+	return statements[name]
+}
+
+// CreateSession also drops the expired sessions of the user, which keeps the
+// table from growing without a separate cleanup job.
+func (p *postgresAuthRepository) CreateSession(ctx context.Context, session models.Session) error {
+	if _, err := p.conn.Exec(p.statement(deleteExpiredSessions), session.Nickname); err != nil {
+		return apperrors.FromPg(err)
+	}
+
+	_, err := p.conn.Exec(p.statement(insertSession), session.TokenHash, session.Nickname, session.Created,
+		session.Expires)
+	if errors.Is(apperrors.FromPg(err), apperrors.ErrNotFound) {
+		return apperrors.NotFound("Can't find user by nickname: %s", session.Nickname)
+	}
+	return apperrors.FromPg(err)
+}
+
+func (p *postgresAuthRepository) GetSession(ctx context.Context, tokenHash string) (models.Session, error) {
+	var session models.Session
+	var role string
+	err := p.conn.QueryRow(p.statement(sessionByToken), tokenHash).Scan(&session.TokenHash, &session.Nickname,
+		&session.Created, &session.Expires, &role)
+	if err == pgx.ErrNoRows {
+		return models.Session{}, apperrors.NotFound("Session not found")
+	}
+	session.Role = userModels.Role(role)
+	return session, apperrors.FromPg(err)
+}
+
+func (p *postgresAuthRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	tag, err := p.conn.Exec(p.statement(deleteSession), tokenHash)
+	if err != nil {
+		return apperrors.FromPg(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.NotFound("Session not found")
+	}
+	return nil
+}
+
+const (
+	insertAPITokenQuery = `INSERT INTO api_token(token_hash, nickname, name, scopes) VALUES ($1, $2, $3, $4)
+RETURNING id, created, nickname`
+	apiTokensByUserQuery = `SELECT id, token_hash, nickname, name, scopes, created, last_used FROM api_token
+WHERE nickname = $1 ORDER BY id`
+	deleteAPITokenQuery = `DELETE FROM api_token WHERE id = $1 AND nickname = $2`
+)
+
+func scanAPIToken(row interface{ Scan(...interface{}) error }) (models.APIToken, error) {
+	var token models.APIToken
+	err := row.Scan(&token.ID, &token.TokenHash, &token.Nickname, &token.Name, &token.Scopes, &token.Created,
+		&token.LastUsed)
+	return token, err
+}
+
+func (p *postgresAuthRepository) CreateAPIToken(ctx context.Context, token models.APIToken) (models.APIToken, error) {
+	err := p.conn.QueryRow(insertAPITokenQuery, token.TokenHash, token.Nickname, token.Name, token.Scopes).
+		Scan(&token.ID, &token.Created, &token.Nickname)
+	if errors.Is(apperrors.FromPg(err), apperrors.ErrNotFound) {
+		return models.APIToken{}, apperrors.NotFound("Can't find user by nickname: %s", token.Nickname)
+	}
+	if err != nil {
+		return models.APIToken{}, apperrors.FromPg(err)
+	}
+	return token, nil
+}
+
+func (p *postgresAuthRepository) GetAPITokens(ctx context.Context, nickname string) ([]models.APIToken, error) {
+	rows, err := p.conn.Query(apiTokensByUserQuery, nickname)
+	if err != nil {
+		return nil, apperrors.FromPg(err)
+	}
+	defer rows.Close()
+
+	tokens := make([]models.APIToken, 0)
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, apperrors.FromPg(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, apperrors.FromPg(rows.Err())
+}
+
+func (p *postgresAuthRepository) UseAPIToken(ctx context.Context, tokenHash string) (models.APIToken, error) {
+	var token models.APIToken
+	var role string
+	err := p.conn.QueryRow(p.statement(useAPIToken), tokenHash).Scan(&token.ID, &token.TokenHash, &token.Nickname,
+		&token.Name, &token.Scopes, &token.Created, &token.LastUsed, &role)
+	if err == pgx.ErrNoRows {
+		return models.APIToken{}, apperrors.NotFound("API token not found")
+	}
+	token.Role = userModels.Role(role)
+	return token, apperrors.FromPg(err)
+}
+
+func (p *postgresAuthRepository) DeleteAPIToken(ctx context.Context, nickname string, id int64) error {
+	tag, err := p.conn.Exec(deleteAPITokenQuery, id, nickname)
+	if err != nil {
+		return apperrors.FromPg(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.NotFound("Can't find API token: %d", id)
+	}
+	return nil
+}
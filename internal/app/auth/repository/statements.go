@@ -0,0 +1,35 @@
+package repository
+
+import "github.com/jackc/pgx"
+
+const (
+	insertSession         = "insert_session"
+	deleteExpiredSessions = "delete_expired_sessions"
+	sessionByToken        = "session_by_token"
+	deleteSession         = "delete_session"
+	useAPIToken           = "use_api_token"
+)
+
+var statements = map[string]string{
+	insertSession:         `INSERT INTO session(token_hash, nickname, created, expires) VALUES ($1, $2, $3, $4)`,
+	deleteExpiredSessions: `DELETE FROM session WHERE nickname = $1 AND expires <= now()`,
+	sessionByToken: `SELECT session.token_hash, session.nickname, session.created, session.expires, users.role
+	FROM session JOIN users ON users.nickname = session.nickname
+	WHERE session.token_hash = $1 AND session.expires > now()`,
+	deleteSession: `DELETE FROM session WHERE token_hash = $1`,
+	useAPIToken: `UPDATE api_token SET last_used = now() FROM users
+	WHERE api_token.token_hash = $1 AND users.nickname = api_token.nickname
+	RETURNING api_token.id, api_token.token_hash, api_token.nickname, api_token.name, api_token.scopes,
+	api_token.created, api_token.last_used, users.role`,
+}
+
+// PrepareStatements registers the queries of the auth repository, which run
+// on every authenticated request, on a new pool connection.
+func PrepareStatements(conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(name, sql); err != nil {
+			return err
+		}
+	}
+	return nil
+}
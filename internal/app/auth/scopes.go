@@ -0,0 +1,67 @@
+package auth
+
+import "strings"
+
+// Scopes limit what an API token may do. Sessions are not limited.
+const (
+	ScopeRead  = "read"
+	ScopePost  = "post"
+	ScopeVote  = "vote"
+	ScopeAdmin = "admin"
+)
+
+var scopes = []string{ScopeRead, ScopePost, ScopeVote, ScopeAdmin}
+
+// APITokenPrefix starts every API token, which tells them apart from
+// session tokens without a lookup.
+const APITokenPrefix = "fpat_"
+
+func ValidScope(scope string) bool {
+	for _, known := range scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether granted includes scope. The admin scope includes
+// all others, and every token has the empty scope.
+func Allows(granted []string, scope string) bool {
+	if scope == "" {
+		return true
+	}
+	for _, g := range granted {
+		if g == scope || g == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiredScope returns the scope an API token needs for a request. Reading,
+// including the list of the user's own tokens, needs read, voting vote and
+// writing to forums, threads and posts post. Logging in and out and revoking
+// tokens need no scope, since they can't add to what the token may do.
+// Everything else, such as managing users, creating tokens and the service,
+// needs admin.
+func RequiredScope(method, path string) string {
+	switch {
+	case path == "/api/auth/login", path == "/api/auth/logout",
+		method == "DELETE" && strings.HasPrefix(path, "/api/auth/tokens/"):
+		return ""
+	case path == "/api/auth/tokens" && (method == "GET" || method == "HEAD"):
+		return ScopeRead
+	case strings.HasPrefix(path, "/api/auth/"), strings.HasPrefix(path, "/api/service/"):
+		return ScopeAdmin
+	case method == "GET" || method == "HEAD":
+		return ScopeRead
+	case strings.HasPrefix(path, "/api/thread/") && strings.HasSuffix(path, "/vote"):
+		return ScopeVote
+	case strings.HasPrefix(path, "/api/forum/"), strings.HasPrefix(path, "/api/thread/"),
+		strings.HasPrefix(path, "/api/post/"):
+		return ScopePost
+	default:
+		return ScopeAdmin
+	}
+}
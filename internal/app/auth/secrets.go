@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MinPasswordLength = 8
+	// MaxPasswordLength is the most bcrypt looks at; longer passwords would
+	// be silently truncated.
+	MaxPasswordLength = 72
+
+	tokenBytes = 32
+
+	// dummyHash is checked in place of a missing hash, so that a login for an
+	// unknown nickname takes as long as one with a wrong password.
+	dummyHash = "$2a$10$N4KoaEiEb8cK3Z7oWYYtp.rzvdtkjQTebHAq0Inu7RhROtLkaD3Wu"
+)
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword reports whether password matches hash. An empty hash, as
+// stored for accounts without a password, matches nothing but still costs a
+// bcrypt comparison.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NewToken returns a random token to hand out to the client together with
+// the hash to store in its place.
+func NewToken() (token, hash string, err error) {
+	raw := make([]byte, tokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, HashToken(token), nil
+}
+
+// NewAPIToken is NewToken for API tokens, which carry APITokenPrefix.
+func NewAPIToken() (token, hash string, err error) {
+	token, _, err = NewToken()
+	if err != nil {
+		return "", "", err
+	}
+	token = APITokenPrefix + token
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the form a token is stored and looked up in. Tokens are
+// random, so a fast hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,119 @@
+package delivery
+
+import (
+	"DbProjectForum/internal/app/forum/models"
+	userModels "DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/identity"
+	"errors"
+	"github.com/valyala/fasthttp"
+	"strings"
+)
+
+// sessionUser returns the nickname of the logged in user, or an empty string
+// for an anonymous request that may still write.
+func (f *forumHandler) sessionUser(ctx *fasthttp.RequestCtx) (string, error) {
+	nickname := identity.FromContext(ctx)
+	if nickname == "" && f.requireAuth {
+		return "", apperrors.Unauthorized("Log in to write to the forum")
+	}
+	return nickname, nil
+}
+
+// actor returns the nickname a write is made as. A logged in user writes as
+// themselves: the body may leave the nickname out but not name someone else.
+// Anonymous requests act as whoever the body names, as the API always did,
+// unless that user has a password; see checkUnprotected.
+func (f *forumHandler) actor(ctx *fasthttp.RequestCtx, claimed string) (string, error) {
+	nickname, err := f.sessionUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	if nickname == "" {
+		return claimed, f.checkUnprotected(ctx, claimed)
+	}
+	if claimed != "" && !strings.EqualFold(claimed, nickname) {
+		return "", apperrors.Forbidden("Logged in as %s, can't write as %s", nickname, claimed)
+	}
+	return nickname, nil
+}
+
+// checkEditor lets a logged in user change what they wrote, and moderators
+// and admins change anything in their reach. Anonymous requests may change
+// only what users without a password wrote. load looks up the author and the
+// forum of the content.
+func (f *forumHandler) checkEditor(ctx *fasthttp.RequestCtx, load func() (author, forum string, err error)) error {
+	nickname, err := f.sessionUser(ctx)
+	if err != nil || identity.IsAdmin(ctx) {
+		return err
+	}
+	author, forumSlug, err := load()
+	if err != nil {
+		return err
+	}
+	if nickname == "" {
+		return f.checkUnprotected(ctx, author)
+	}
+	if strings.EqualFold(author, nickname) {
+		return nil
+	}
+	moderates, err := f.forumRepo.IsModerator(ctx, forumSlug, nickname)
+	if err != nil {
+		return err
+	}
+	if !moderates {
+		return apperrors.Forbidden("Only %s or a moderator of %s can edit this", author, forumSlug)
+	}
+	return nil
+}
+
+// checkUnprotected lets anonymous requests act as nickname only when that
+// user has no password, so that an account can't be written as without its
+// password, and is not banned, as a session of theirs couldn't write either.
+// Unknown users pass; the write itself reports them.
+func (f *forumHandler) checkUnprotected(ctx *fasthttp.RequestCtx, nickname string) error {
+	if nickname == "" {
+		return nil
+	}
+	userObj, err := f.userRepo.GetByNick(ctx, nickname)
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case userObj.PasswordHash != "":
+		return apperrors.Unauthorized("Log in as %s to write as them", userObj.Nickname)
+	case userObj.Role == userModels.RoleBanned:
+		return apperrors.Forbidden("User %s is banned", userObj.Nickname)
+	default:
+		return nil
+	}
+}
+
+// checkLoggedInAdmin guards the service routes. Clearing the database and
+// purging posts are too much to allow anonymously, so it needs a logged in
+// admin even when auth is not required.
+func checkLoggedInAdmin(ctx *fasthttp.RequestCtx) error {
+	switch {
+	case identity.FromContext(ctx) == "":
+		return apperrors.Unauthorized("Log in as an admin to do this")
+	case identity.IsAdmin(ctx):
+		return nil
+	default:
+		return apperrors.Forbidden("Only admins can do this")
+	}
+}
+
+// checkForumOwner lets admins and the owner of the forum manage its
+// moderators. It needs a logged in user even when auth is not required.
+func checkForumOwner(ctx *fasthttp.RequestCtx, forumObj models.Forum) error {
+	nickname := identity.FromContext(ctx)
+	switch {
+	case nickname == "":
+		return apperrors.Unauthorized("Log in to manage moderators")
+	case identity.IsAdmin(ctx), strings.EqualFold(forumObj.User, nickname):
+		return nil
+	default:
+		return apperrors.Forbidden("Only %s or an admin can manage moderators of %s", forumObj.User, forumObj.Slug)
+	}
+}
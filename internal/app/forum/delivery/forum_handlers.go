@@ -4,24 +4,28 @@ import (
 	"DbProjectForum/internal/app/forum"
 	"DbProjectForum/internal/app/forum/models"
 	"DbProjectForum/internal/app/user"
+	"DbProjectForum/internal/pkg/apperrors"
 	"DbProjectForum/internal/pkg/responses"
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/fasthttp/router"
-	"github.com/jackc/pgx"
 	"github.com/valyala/fasthttp"
 	"strconv"
 	"strings"
 )
 
 type forumHandler struct {
-	forumRepo forum.Repository
-	userRepo  user.Repository
+	forumRepo   forum.Repository
+	userRepo    user.Repository
+	requireAuth bool
 }
 
-func NewForumHandler(r *router.Router, fr forum.Repository, ur user.Repository) {
-	handler := forumHandler{forumRepo: fr, userRepo: ur}
+// NewForumHandler registers the forum routes. With requireAuth, creating and
+// editing content needs a session; without it, so does acting as a user that
+// has a password. See actor.
+func NewForumHandler(r *router.Router, fr forum.Repository, ur user.Repository, requireAuth bool) {
+	handler := forumHandler{forumRepo: fr, userRepo: ur, requireAuth: requireAuth}
 
 	r.POST("/api/forum/create", handler.Add)
 	r.GET("/api/forum/{slug}/details", handler.Get)
@@ -29,6 +33,10 @@ func NewForumHandler(r *router.Router, fr forum.Repository, ur user.Repository)
 
 	r.GET("/api/forum/{slug}/threads", handler.GetThreads)
 
+	r.GET("/api/forum/{slug}/moderators", handler.GetModerators)
+	r.POST("/api/forum/{slug}/moderators", handler.AddModerator)
+	r.DELETE("/api/forum/{slug}/moderators/{nickname}", handler.RemoveModerator)
+
 	r.GET("/api/thread/{slug_or_id}/details", handler.GetThreadDetailsSlug)
 
 	r.POST("/api/thread/{slug_or_id}/details", handler.UpdateThreadBySlugOrID)
@@ -38,52 +46,42 @@ func NewForumHandler(r *router.Router, fr forum.Repository, ur user.Repository)
 
 	r.GET("/api/post/{id:[0-9]+}/details", handler.GetPostByID)
 	r.POST("/api/post/{id:[0-9]+}/details", handler.UpdatePost)
+	r.DELETE("/api/post/{id:[0-9]+}/details", handler.DeletePost)
 
 	r.POST("/api/thread/{id:[0-9]+}/vote", handler.AddVoteID)
 	r.POST("/api/thread/{slug}/vote", handler.AddVoteSlug)
 
+	r.GET("/api/search", handler.Search)
+
 	r.GET("/api/service/status", handler.GetServiceStatus)
 	r.POST("/api/service/clear", handler.ClearDataBase)
+	r.POST("/api/service/purge", handler.PurgeDeletedPosts)
 }
 
 func (f *forumHandler) Add(ctx *fasthttp.RequestCtx) {
 	var newForum models.Forum
 	err := json.Unmarshal(ctx.PostBody(), &newForum)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid forum: %s", err), ctx)
+		return
+	}
+	if newForum.User, err = f.actor(ctx, newForum.User); err != nil {
+		responses.SendError(err, ctx)
 		return
 	}
 
-	newForumDB, err := f.forumRepo.Add(newForum)
-	if pgerr, ok := err.(pgx.PgError); ok {
-		switch pgerr.Code {
-		case "23505":
-			forumObj, err := f.forumRepo.GetBySlug(newForum.Slug)
-			if err != nil {
-				responses.SendServerError(err.Error(), ctx)
-				return
-			}
-			responses.SendResponse(409, forumObj, ctx)
-			return
-		case "23503":
-			err := responses.HttpError{
-				Message: fmt.Sprintf("Can't find user with nickname: %s", newForum.User),
-			}
-			responses.SendResponse(404, err, ctx)
+	newForumDB, err := f.forumRepo.Add(ctx, newForum)
+	if errors.Is(err, apperrors.ErrConflict) {
+		forumObj, err := f.forumRepo.GetBySlug(ctx, newForum.Slug)
+		if err != nil {
+			responses.SendError(err, ctx)
 			return
 		}
-
-	}
-	if err == pgx.ErrNoRows {
-		err := responses.HttpError{
-			Message: fmt.Sprintf("Can't find user with nickname: %s", newForum.User),
-		}
-		responses.SendResponse(404, err, ctx)
+		responses.SendResponse(409, forumObj, ctx)
 		return
 	}
-
 	if err != nil {
-		responses.SendResponse(400, err.Error(), ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
@@ -97,23 +95,91 @@ func (f *forumHandler) Get(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	forumObj, err := f.forumRepo.GetBySlug(slug)
-	switch err {
-	case pgx.ErrNoRows:
-		err := responses.HttpError{
-			Message: fmt.Sprintf("Can't find forum with slug: %s", slug),
-		}
-		responses.SendResponse(404, err, ctx)
+	forumObj, err := f.forumRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		responses.SendError(err, ctx)
 		return
-	case nil:
-	default:
-		responses.SendServerError(err.Error(), ctx)
 	}
 
 	responses.SendResponseOK(forumObj, ctx)
 	return
 }
 
+// sendModerators answers with the moderators of the forum, not counting its
+// owner.
+func (f *forumHandler) sendModerators(ctx *fasthttp.RequestCtx, status int, slug string) {
+	moderators, err := f.userRepo.GetModerators(ctx, slug)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	responses.SendResponse(status, moderators, ctx)
+}
+
+func (f *forumHandler) GetModerators(ctx *fasthttp.RequestCtx) {
+	slug, _ := ctx.UserValue("slug").(string)
+	forumObj, err := f.forumRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	f.sendModerators(ctx, 200, forumObj.Slug)
+}
+
+// AddModerator grants moderator status on the forum to the user named in the
+// body. Only admins and the owner of the forum may do that.
+func (f *forumHandler) AddModerator(ctx *fasthttp.RequestCtx) {
+	slug, _ := ctx.UserValue("slug").(string)
+	forumObj, err := f.forumRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	if err := checkForumOwner(ctx, forumObj); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	var request struct {
+		Nickname string `json:"nickname"`
+	}
+	if err := json.Unmarshal(ctx.PostBody(), &request); err != nil {
+		responses.SendError(apperrors.Validation("Invalid moderator: %s", err), ctx)
+		return
+	}
+	if request.Nickname == "" {
+		responses.SendError(apperrors.Validation("Moderator nickname must not be empty"), ctx)
+		return
+	}
+
+	if err := f.forumRepo.AddModerator(ctx, forumObj.Slug, request.Nickname); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	f.sendModerators(ctx, 201, forumObj.Slug)
+}
+
+func (f *forumHandler) RemoveModerator(ctx *fasthttp.RequestCtx) {
+	slug, _ := ctx.UserValue("slug").(string)
+	nickname, _ := ctx.UserValue("nickname").(string)
+	forumObj, err := f.forumRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	if err := checkForumOwner(ctx, forumObj); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	if err := f.forumRepo.RemoveModerator(ctx, forumObj.Slug, nickname); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	f.sendModerators(ctx, 200, forumObj.Slug)
+}
+
 func (f *forumHandler) AddThread(ctx *fasthttp.RequestCtx) {
 	forumSlug, found := ctx.UserValue("slug").(string)
 	if !found {
@@ -125,15 +191,19 @@ func (f *forumHandler) AddThread(ctx *fasthttp.RequestCtx) {
 
 	err := json.Unmarshal(ctx.PostBody(), &newThread)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid thread: %s", err), ctx)
+		return
+	}
+	if newThread.Author, err = f.actor(ctx, newThread.Author); err != nil {
+		responses.SendError(err, ctx)
 		return
 	}
 
-	newThreadDB, err := f.forumRepo.AddThread(newThread)
-	if pgerr, ok := err.(pgx.PgError); ok && pgerr.Code == "23505" {
-		threadOld, err := f.forumRepo.GetThreadBySlug(newThread.Slug.String)
+	newThreadDB, err := f.forumRepo.AddThread(ctx, newThread)
+	if errors.Is(err, apperrors.ErrConflict) {
+		threadOld, err := f.forumRepo.GetThreadBySlug(ctx, newThread.Slug.String)
 		if err != nil {
-			responses.SendServerError(err.Error(), ctx)
+			responses.SendError(err, ctx)
 			return
 		}
 		responses.SendResponse(409, threadOld, ctx)
@@ -141,8 +211,7 @@ func (f *forumHandler) AddThread(ctx *fasthttp.RequestCtx) {
 	}
 
 	if err != nil {
-		errHttp := responses.HttpError{Message: err.Error()}
-		responses.SendResponse(404, errHttp, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
@@ -182,6 +251,17 @@ func extractIntValue(ctx *fasthttp.RequestCtx, valueName string) (int, error) {
 	return value, nil
 }
 
+// threadID resolves the slug_or_id path parameter to a thread id.
+func (f *forumHandler) threadID(ctx *fasthttp.RequestCtx, slugOrID string) (int, error) {
+	id, err := strconv.Atoi(slugOrID)
+	if err != nil {
+		return f.forumRepo.GetThreadIDBySlug(ctx, slugOrID)
+	}
+
+	_, err = f.forumRepo.GetThreadByID(ctx, id)
+	return id, err
+}
+
 func (f *forumHandler) GetThreads(ctx *fasthttp.RequestCtx) {
 	forumSlug, found := ctx.UserValue("slug").(string)
 	if !found {
@@ -191,7 +271,7 @@ func (f *forumHandler) GetThreads(ctx *fasthttp.RequestCtx) {
 
 	limit, err := extractIntValue(ctx, "limit")
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid limit: %s", err), ctx)
 		return
 	}
 
@@ -199,32 +279,23 @@ func (f *forumHandler) GetThreads(ctx *fasthttp.RequestCtx) {
 
 	desc, err := extractBoolValue(ctx, "desc")
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid desc: %s", err), ctx)
+		return
+	}
+	threads, err := f.forumRepo.GetThreads(ctx, forumSlug, limit, since, desc)
+	if err != nil {
+		responses.SendError(err, ctx)
 		return
 	}
-	threads, err := f.forumRepo.GetThreads(forumSlug, limit, since, desc)
-	if err == pgx.ErrNoRows || len(threads) == 0 {
-		exists, err := f.forumRepo.CheckThreadExists(forumSlug)
+
+	if len(threads) == 0 {
+		_, err := f.forumRepo.GetBySlug(ctx, forumSlug)
 		if err != nil {
-			responses.SendServerError(err.Error(), ctx)
+			responses.SendError(err, ctx)
 			return
 		}
-		if exists {
-			data := make([]models.Thread, 0, 0)
-			responses.SendResponseOK(data, ctx)
-			return
-		}
-		errHTTP := responses.HttpError{
-			Message: fmt.Sprintf("Can't find forum by slug: %s", forumSlug),
-		}
-		responses.SendResponse(404, errHTTP, ctx)
-		return
 	}
 
-	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
-		return
-	}
 	responses.SendResponseOK(threads, ctx)
 	return
 }
@@ -233,46 +304,31 @@ func (f *forumHandler) createPost(ctx *fasthttp.RequestCtx, id int) {
 	var newPosts []models.Post
 	err := json.Unmarshal(ctx.PostBody(), &newPosts)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid posts: %s", err), ctx)
 		return
 	}
-	if len(newPosts) == 0 {
-		responses.SendResponse(201, newPosts, ctx)
-		return
-	}
-	newPostsAuthor := newPosts[0].Author
-	newPosts, err = f.forumRepo.AddPosts(newPosts, id)
-	if len(newPosts) == 0 {
-		err = pgx.ErrNoRows
-	}
-	if err != nil {
-		if pgerr, ok := err.(pgx.PgError); ok {
-			switch pgerr.Code {
-			case "00409":
-				responses.SendResponse(409, map[int]int{}, ctx)
-				return
-			}
-		}
-
-		if err == pgx.ErrNoRows {
-			_, err = f.forumRepo.GetThreadByID(id)
-			if err == pgx.ErrNoRows {
-				responses.SendResponse(404, map[int]int{}, ctx)
-				return
-			}
-			_, err = f.userRepo.GetByNick(newPostsAuthor)
-			if err == pgx.ErrNoRows {
-				responses.SendResponse(404, map[int]int{}, ctx)
+	// Batches often repeat authors, and each anonymous author costs a lookup.
+	authors := make(map[string]string)
+	for i := range newPosts {
+		claimed := strings.ToLower(newPosts[i].Author)
+		author, checked := authors[claimed]
+		if !checked {
+			if author, err = f.actor(ctx, newPosts[i].Author); err != nil {
+				responses.SendError(err, ctx)
 				return
 			}
-			responses.SendResponse(409, map[int]int{}, ctx)
-			return
+			authors[claimed] = author
 		}
+		newPosts[i].Author = author
+	}
+	if len(newPosts) == 0 {
+		responses.SendResponse(201, []models.Post{}, ctx)
+		return
+	}
 
-		httpError := map[string]string{
-			"message": err.Error(),
-		}
-		responses.SendResponse(404, httpError, ctx)
+	newPosts, err = f.forumRepo.AddPosts(ctx, newPosts, id)
+	if err != nil {
+		responses.SendError(err, ctx)
 		return
 	}
 
@@ -286,138 +342,78 @@ func (f *forumHandler) AddPostSlug(ctx *fasthttp.RequestCtx) {
 		responses.SendResponse(400, "bad request", ctx)
 		return
 	}
-	var id int
-	id, err := strconv.Atoi(slugOrId)
-	if err == nil {
-		_, err = f.forumRepo.GetThreadByID(id)
-		if err != nil {
-			errHTTP := responses.HttpError{
-				Message: fmt.Sprintf(err.Error()),
-			}
-			responses.SendResponse(404, errHTTP, ctx)
 
-			return
-		}
-	} else {
-		id, err = f.forumRepo.GetThreadIDBySlug(slugOrId)
-		if err != nil {
-			errHTTP := responses.HttpError{
-				Message: fmt.Sprintf(err.Error()),
-			}
-			responses.SendResponse(404, errHTTP, ctx)
-			return
-		}
+	id, err := f.threadID(ctx, slugOrId)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
 	}
 
 	f.createPost(ctx, id)
-
 }
 
-func (f *forumHandler) AddVoteSlug(ctx *fasthttp.RequestCtx) {
-	threadSlug, found := ctx.UserValue("slug").(string)
-	if !found {
-		responses.SendResponse(400, "bad request", ctx)
-		return
-	}
-
+// vote stores the voice of a user for the thread, replacing a previous one.
+func (f *forumHandler) vote(ctx *fasthttp.RequestCtx, threadID int) {
 	var newVote models.Vote
 	err := json.Unmarshal(ctx.PostBody(), &newVote)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid vote: %s", err), ctx)
+		return
+	}
+	if newVote.Nickname, err = f.actor(ctx, newVote.Nickname); err != nil {
+		responses.SendError(err, ctx)
 		return
 	}
-	threadID, _ := f.forumRepo.GetThreadIDBySlug(threadSlug)
 	newVote.IdThread = int64(threadID)
-	err = f.forumRepo.AddVote(newVote)
-	if err != nil {
-		pgerr, ok := err.(pgx.PgError)
-		if !ok {
-			errHTTP := responses.HttpError{
-				Message: fmt.Sprintf(err.Error()),
-			}
-			responses.SendResponse(404, errHTTP, ctx)
-			return
-		}
-		if pgerr.Code != "23505" {
-			errHTTP := responses.HttpError{
-				Message: fmt.Sprintf(err.Error()),
-			}
-			responses.SendResponse(404, errHTTP, ctx)
-			return
-		}
 
+	err = f.forumRepo.AddVote(ctx, newVote)
+	if errors.Is(err, apperrors.ErrConflict) {
+		err = f.forumRepo.UpdateVote(ctx, newVote)
+	}
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
 	}
 
-	updatedThread, err := f.forumRepo.GetThreadBySlug(threadSlug)
+	updatedThread, err := f.forumRepo.GetThreadByID(ctx, threadID)
 	if err != nil {
-		errHTTP := responses.HttpError{
-			Message: fmt.Sprintf(err.Error()),
-		}
-		responses.SendResponse(404, errHTTP, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
 	responses.SendResponseOK(updatedThread, ctx)
 }
 
-func (f *forumHandler) AddVoteID(ctx *fasthttp.RequestCtx) {
-	ValueStr, found := ctx.UserValue("id").(string)
+func (f *forumHandler) AddVoteSlug(ctx *fasthttp.RequestCtx) {
+	threadSlug, found := ctx.UserValue("slug").(string)
 	if !found {
 		responses.SendResponse(400, "bad request", ctx)
 		return
 	}
 
-	value, err := strconv.Atoi(ValueStr)
+	threadID, err := f.forumRepo.GetThreadIDBySlug(ctx, threadSlug)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
-	var newVote models.Vote
-	err = json.Unmarshal(ctx.PostBody(), &newVote)
-	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+	f.vote(ctx, threadID)
+}
+
+func (f *forumHandler) AddVoteID(ctx *fasthttp.RequestCtx) {
+	ValueStr, found := ctx.UserValue("id").(string)
+	if !found {
+		responses.SendResponse(400, "bad request", ctx)
 		return
 	}
-	newVote.IdThread = int64(value)
 
-	err = f.forumRepo.AddVote(newVote)
+	threadID, err := f.threadID(ctx, ValueStr)
 	if err != nil {
-		pgerr, ok := err.(pgx.PgError)
-		if !ok {
-			errHTTP := responses.HttpError{
-				Message: fmt.Sprintf(err.Error()),
-			}
-			responses.SendResponse(404, errHTTP, ctx)
-			return
-		}
-		if pgerr.Code != "23505" {
-			errHTTP := responses.HttpError{
-				Message: fmt.Sprintf(err.Error()),
-			}
-			responses.SendResponse(404, errHTTP, ctx)
-			return
-		} else {
-			err = f.forumRepo.UpdateVote(newVote)
-			if err != nil {
-				errHTTP := responses.HttpError{
-					Message: fmt.Sprintf(err.Error()),
-				}
-				responses.SendResponse(404, errHTTP, ctx)
-				return
-			}
-		}
-	}
-	updatedThread, err := f.forumRepo.GetThreadByID(value)
-	if err != nil {
-		errHTTP := responses.HttpError{
-			Message: fmt.Sprintf(err.Error()),
-		}
-		responses.SendResponse(404, errHTTP, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
-	responses.SendResponseOK(updatedThread, ctx)
+	f.vote(ctx, threadID)
 }
 
 func (f *forumHandler) GetThreadDetailsSlug(ctx *fasthttp.RequestCtx) {
@@ -429,22 +425,16 @@ func (f *forumHandler) GetThreadDetailsSlug(ctx *fasthttp.RequestCtx) {
 
 	id, err := strconv.Atoi(threadSlug)
 	if err != nil {
-		id, err = f.forumRepo.GetThreadIDBySlug(threadSlug)
+		id, err = f.forumRepo.GetThreadIDBySlug(ctx, threadSlug)
 		if err != nil {
-			errHTTP := responses.HttpError{
-				Message: fmt.Sprintf(err.Error()),
-			}
-			responses.SendResponse(404, errHTTP, ctx)
+			responses.SendError(err, ctx)
 			return
 		}
 	}
 
-	forumObj, err := f.forumRepo.GetThreadByID(id)
+	forumObj, err := f.forumRepo.GetThreadByID(ctx, id)
 	if err != nil {
-		errHTTP := responses.HttpError{
-			Message: fmt.Sprintf(err.Error()),
-		}
-		responses.SendResponse(404, errHTTP, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
@@ -469,13 +459,26 @@ func (f *forumHandler) UpdateThreadBySlugOrID(ctx *fasthttp.RequestCtx) {
 
 	err := json.Unmarshal(ctx.PostBody(), &newThread)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid thread: %s", err), ctx)
+		return
+	}
+
+	err = f.checkEditor(ctx, func() (string, string, error) {
+		if newThread.Id != 0 {
+			thread, err := f.forumRepo.GetThreadByID(ctx, int(newThread.Id))
+			return thread.Author, thread.Forum, err
+		}
+		thread, err := f.forumRepo.GetThreadBySlug(ctx, threadSlugOrID)
+		return thread.Author, thread.Forum, err
+	})
+	if err != nil {
+		responses.SendError(err, ctx)
 		return
 	}
 
-	thread, err := f.forumRepo.UpdateThread(newThread)
+	thread, err := f.forumRepo.UpdateThread(ctx, newThread)
 	if err != nil {
-		responses.SendResponse(404, err, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
@@ -492,13 +495,13 @@ func (f *forumHandler) GetPostsSlug(ctx *fasthttp.RequestCtx) {
 
 	limit, err := extractIntValue(ctx, "limit")
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid limit: %s", err), ctx)
 		return
 	}
 
 	since, err := extractIntValue(ctx, "since")
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid since: %s", err), ctx)
 		return
 	}
 
@@ -509,7 +512,7 @@ func (f *forumHandler) GetPostsSlug(ctx *fasthttp.RequestCtx) {
 
 	desc, err := extractBoolValue(ctx, "desc")
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid desc: %s", err), ctx)
 		return
 	}
 	var slugOrID models.Thread
@@ -520,21 +523,17 @@ func (f *forumHandler) GetPostsSlug(ctx *fasthttp.RequestCtx) {
 	slugJSON := models.JsonNullString{NullString: slug}
 	slugOrID.Slug = slugJSON
 
-	posts, err := f.forumRepo.GetPosts(slugOrID, limit, since, sortType, desc)
+	posts, err := f.forumRepo.GetPosts(ctx, slugOrID, limit, since, sortType, desc)
 	if err != nil {
-		errHTTP := responses.HttpError{
-			Message: fmt.Sprintf(err.Error()),
-		}
-		responses.SendResponse(404, errHTTP, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
 	if posts == nil {
 		if slugOrID.Id != 0 {
-			_, err := f.forumRepo.GetThreadByID(int(slugOrID.Id))
-			if err == pgx.ErrNoRows {
-				httpErr := responses.HttpError{Message: err.Error()}
-				responses.SendResponse(404, httpErr, ctx)
+			_, err := f.forumRepo.GetThreadByID(ctx, int(slugOrID.Id))
+			if err != nil {
+				responses.SendError(err, ctx)
 				return
 			}
 		}
@@ -555,16 +554,15 @@ func (f *forumHandler) GetPostByID(ctx *fasthttp.RequestCtx) {
 
 	id, err := strconv.Atoi(ValueStr)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid post id: %s", ValueStr), ctx)
 		return
 	}
 
 	related := string(ctx.QueryArgs().Peek("related"))
 
-	post, err := f.forumRepo.GetPost(id, strings.Split(related, ","))
+	post, err := f.forumRepo.GetPost(ctx, id, strings.Split(related, ","))
 	if err != nil {
-		httpErr := responses.HttpError{Message: err.Error()}
-		responses.SendResponse(404, httpErr, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
@@ -581,7 +579,7 @@ func (f *forumHandler) UpdatePost(ctx *fasthttp.RequestCtx) {
 
 	id, err := strconv.Atoi(ValueStr)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid post id: %s", ValueStr), ctx)
 		return
 	}
 
@@ -591,14 +589,26 @@ func (f *forumHandler) UpdatePost(ctx *fasthttp.RequestCtx) {
 
 	err = json.Unmarshal(ctx.PostBody(), &newPost)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid post: %s", err), ctx)
+		return
+	}
+
+	err = f.checkEditor(ctx, func() (string, string, error) {
+		post, err := f.forumRepo.GetPost(ctx, id, nil)
+		if err != nil {
+			return "", "", err
+		}
+		postObj := post["post"].(models.Post)
+		return postObj.Author, postObj.Forum, nil
+	})
+	if err != nil {
+		responses.SendError(err, ctx)
 		return
 	}
 
-	newPost, err = f.forumRepo.UpdatePost(newPost)
+	newPost, err = f.forumRepo.UpdatePost(ctx, newPost)
 	if err != nil {
-		httpErr := responses.HttpError{Message: err.Error()}
-		responses.SendResponse(404, httpErr, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
@@ -606,10 +616,105 @@ func (f *forumHandler) UpdatePost(ctx *fasthttp.RequestCtx) {
 	return
 }
 
+// DeletePost leaves a tombstone in place of the post so that its replies
+// keep their place in the thread. Who may delete a post is who may edit it.
+func (f *forumHandler) DeletePost(ctx *fasthttp.RequestCtx) {
+	ValueStr, found := ctx.UserValue("id").(string)
+	if !found {
+		responses.SendResponse(400, "bad request", ctx)
+		return
+	}
+
+	id, err := strconv.Atoi(ValueStr)
+	if err != nil {
+		responses.SendError(apperrors.Validation("Invalid post id: %s", ValueStr), ctx)
+		return
+	}
+
+	err = f.checkEditor(ctx, func() (string, string, error) {
+		post, err := f.forumRepo.GetPost(ctx, id, nil)
+		if err != nil {
+			return "", "", err
+		}
+		postObj := post["post"].(models.Post)
+		return postObj.Author, postObj.Forum, nil
+	})
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	post, err := f.forumRepo.DeletePost(ctx, id)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	responses.SendResponseOK(post, ctx)
+}
+
+// Search finds threads and posts by text. Results come best match first;
+// limit caps them and desc orders equal ranks newest first. Paging stops at
+// limit, see models.SearchQuery.
+func (f *forumHandler) Search(ctx *fasthttp.RequestCtx) {
+	query := models.SearchQuery{
+		Text:   string(ctx.QueryArgs().Peek("q")),
+		Forum:  string(ctx.QueryArgs().Peek("forum")),
+		Author: string(ctx.QueryArgs().Peek("author")),
+		Type:   string(ctx.QueryArgs().Peek("type")),
+	}
+	if strings.TrimSpace(query.Text) == "" {
+		responses.SendError(apperrors.Validation("Search query q must not be empty"), ctx)
+		return
+	}
+	if query.Type != "" && query.Type != models.SearchThread && query.Type != models.SearchPost {
+		responses.SendError(apperrors.Validation("Unknown search type: %s", query.Type), ctx)
+		return
+	}
+
+	var err error
+	query.Limit, err = extractIntValue(ctx, "limit")
+	if err != nil {
+		responses.SendError(apperrors.Validation("Invalid limit: %s", err), ctx)
+		return
+	}
+	query.Desc, err = extractBoolValue(ctx, "desc")
+	if err != nil {
+		responses.SendError(apperrors.Validation("Invalid desc: %s", err), ctx)
+		return
+	}
+
+	results, err := f.forumRepo.Search(ctx, query)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	if len(results) == 0 && query.Forum != "" {
+		if _, err := f.forumRepo.GetBySlug(ctx, query.Forum); err != nil {
+			responses.SendError(err, ctx)
+			return
+		}
+	}
+	if len(results) == 0 && query.Author != "" {
+		if _, err := f.userRepo.GetByNick(ctx, query.Author); err != nil {
+			responses.SendError(err, ctx)
+			return
+		}
+	}
+
+	responses.SendResponseOK(results, ctx)
+}
+
 func (f *forumHandler) GetServiceStatus(ctx *fasthttp.RequestCtx) {
-	info, err := f.forumRepo.GetServiceStatus()
+	if err := checkLoggedInAdmin(ctx); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	info, err := f.forumRepo.GetServiceStatus(ctx)
 	if err != nil {
-		responses.SendResponse(404, err.Error(), ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 	responses.SendResponseOK(info, ctx)
@@ -617,11 +722,32 @@ func (f *forumHandler) GetServiceStatus(ctx *fasthttp.RequestCtx) {
 }
 
 func (f *forumHandler) ClearDataBase(ctx *fasthttp.RequestCtx) {
-	err := f.forumRepo.ClearDatabase()
+	if err := checkLoggedInAdmin(ctx); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	err := f.forumRepo.ClearDatabase(ctx)
 	if err != nil {
-		responses.SendResponse(404, err.Error(), ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 	responses.SendResponseOK("", ctx)
 	return
 }
+
+// PurgeDeletedPosts removes the tombstones that no living reply needs
+// anymore.
+func (f *forumHandler) PurgeDeletedPosts(ctx *fasthttp.RequestCtx) {
+	if err := checkLoggedInAdmin(ctx); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	purged, err := f.forumRepo.PurgeDeletedPosts(ctx)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	responses.SendResponseOK(models.PurgeResult{Posts: purged}, ctx)
+}
@@ -78,15 +78,16 @@ func (v *JsonNullString) UnmarshalJSON(data []byte) error {
 }
 
 type Post struct {
-	Author   string           `json:"author"`
-	Created  string           `json:"created"`
-	Forum    string           `json:"forum"`
-	Id       int64            `json:"id"`
-	IsEdited bool             `json:"isEdited"`
-	Message  string           `json:"message"`
-	Parent   JsonNullInt64    `json:"parent"`
-	Thread   int32            `json:"thread"`
-	Path     pgtype.Int8Array `json:"-"`
+	Author    string           `json:"author"`
+	Created   string           `json:"created"`
+	Forum     string           `json:"forum"`
+	Id        int64            `json:"id"`
+	IsEdited  bool             `json:"isEdited"`
+	IsDeleted bool             `json:"isDeleted,omitempty"` // a tombstone with its message hidden
+	Message   string           `json:"message"`
+	Parent    JsonNullInt64    `json:"parent"`
+	Thread    int32            `json:"thread"`
+	Path      pgtype.Int8Array `json:"-"`
 }
 
 type Vote struct {
@@ -94,3 +95,34 @@ type Vote struct {
 	Voice    int32  `json:"voice"`
 	IdThread int64  `json:"-"`
 }
+
+const (
+	SearchThread = "thread"
+	SearchPost   = "post"
+)
+
+// SearchQuery selects threads and posts matching Text. Type limits the search
+// to threads or posts and Limit caps the results. Desc orders results of equal
+// rank newest first. There is no since: results come by rank, so a creation
+// time can't mark where a page ends.
+type SearchQuery struct {
+	Text   string
+	Forum  string
+	Author string
+	Type   string
+	Limit  int
+	Desc   bool
+}
+
+type SearchResult struct {
+	Type    string  `json:"type"`
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+	Thread  *Thread `json:"thread,omitempty"`
+	Post    *Post   `json:"post,omitempty"`
+}
+
+// PurgeResult reports how many deleted posts a purge removed for good.
+type PurgeResult struct {
+	Posts int64 `json:"posts"`
+}
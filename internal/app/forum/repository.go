@@ -2,29 +2,44 @@ package forum
 
 import (
 	"DbProjectForum/internal/app/forum/models"
+	"context"
 )
 
 type Repository interface {
-	Add(forum models.Forum) (models.Forum, error)
-	GetBySlug(slug string) (models.Forum, error)
-
-	AddThread(thread models.Thread) (models.Thread, error)
-	UpdateThread(newThread models.Thread) (models.Thread, error)
-	GetThreads(slug string, limit int, since string, desc bool) ([]models.Thread, error)
-	CheckThreadExists(slug string) (bool, error)
-	GetThreadBySlug(slug string) (models.Thread, error)
-	GetThreadByID(id int) (models.Thread, error)
-	GetThreadIDBySlug(slug string) (int, error)
-	GetThreadSlugByID(id int) (string, error)
-
-	AddPosts(posts []models.Post, threadID int) ([]models.Post, error)
-	GetPosts(postSlugOrId models.Thread, limit, since int, sort string, desc bool) ([]models.Post, error)
-	GetPost(id int, related []string) (map[string]interface{}, error)
-	UpdatePost(newPost models.Post) (models.Post, error)
-
-	AddVote(vote models.Vote) error
-	UpdateVote(vote models.Vote) error
-
-	GetServiceStatus() (map[string]int, error)
-	ClearDatabase() error
+	Add(ctx context.Context, forum models.Forum) (models.Forum, error)
+	GetBySlug(ctx context.Context, slug string) (models.Forum, error)
+
+	AddModerator(ctx context.Context, slug, nickname string) error
+	RemoveModerator(ctx context.Context, slug, nickname string) error
+	// IsModerator reports whether the user moderates the forum, which its
+	// owner always does.
+	IsModerator(ctx context.Context, slug, nickname string) (bool, error)
+
+	AddThread(ctx context.Context, thread models.Thread) (models.Thread, error)
+	UpdateThread(ctx context.Context, newThread models.Thread) (models.Thread, error)
+	GetThreads(ctx context.Context, slug string, limit int, since string, desc bool) ([]models.Thread, error)
+	CheckThreadExists(ctx context.Context, slug string) (bool, error)
+	GetThreadBySlug(ctx context.Context, slug string) (models.Thread, error)
+	GetThreadByID(ctx context.Context, id int) (models.Thread, error)
+	GetThreadIDBySlug(ctx context.Context, slug string) (int, error)
+	GetThreadSlugByID(ctx context.Context, id int) (string, error)
+
+	AddPosts(ctx context.Context, posts []models.Post, threadID int) ([]models.Post, error)
+	GetPosts(ctx context.Context, postSlugOrId models.Thread, limit, since int, sort string, desc bool) ([]models.Post, error)
+	GetPost(ctx context.Context, id int, related []string) (map[string]interface{}, error)
+	UpdatePost(ctx context.Context, newPost models.Post) (models.Post, error)
+	// DeletePost turns the post into a tombstone, which keeps its replies in
+	// place, and returns it.
+	DeletePost(ctx context.Context, id int) (models.Post, error)
+	// PurgeDeletedPosts removes the tombstones that have no replies left
+	// besides other tombstones and returns how many were removed.
+	PurgeDeletedPosts(ctx context.Context) (int64, error)
+
+	Search(ctx context.Context, query models.SearchQuery) ([]models.SearchResult, error)
+
+	AddVote(ctx context.Context, vote models.Vote) error
+	UpdateVote(ctx context.Context, vote models.Vote) error
+
+	GetServiceStatus(ctx context.Context) (map[string]int, error)
+	ClearDatabase(ctx context.Context) error
 }
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/forum"
+	"DbProjectForum/internal/app/forum/models"
+	"DbProjectForum/internal/pkg/requestid"
+	"context"
+	"time"
+)
+
+// loggingForumRepository logs every repository call with the ID of the HTTP request
+// that caused it and warns about calls slower than slowQuery.
+type loggingForumRepository struct {
+	next      forum.Repository
+	slowQuery time.Duration
+}
+
+func NewLoggingForumRepository(next forum.Repository, slowQuery time.Duration) forum.Repository {
+	return &loggingForumRepository{
+		next:      next,
+		slowQuery: slowQuery,
+	}
+}
+
+func (l *loggingForumRepository) observe(ctx context.Context, method string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	logger := requestid.Logger(ctx)
+
+	event := logger.Debug()
+	if elapsed >= l.slowQuery {
+		event = logger.Warn()
+	}
+	if err != nil {
+		event = event.Str("error", err.Error())
+	}
+	event.Str("repository", "forum").
+		Str("method", method).
+		Dur("latency", elapsed).
+		Msg("repository call")
+}
+
+func (l *loggingForumRepository) Add(ctx context.Context, forum models.Forum) (models.Forum, error) {
+	start := time.Now()
+	result, err := l.next.Add(ctx, forum)
+	l.observe(ctx, "Add", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) GetBySlug(ctx context.Context, slug string) (models.Forum, error) {
+	start := time.Now()
+	result, err := l.next.GetBySlug(ctx, slug)
+	l.observe(ctx, "GetBySlug", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) AddThread(ctx context.Context, thread models.Thread) (models.Thread, error) {
+	start := time.Now()
+	result, err := l.next.AddThread(ctx, thread)
+	l.observe(ctx, "AddThread", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) UpdateThread(ctx context.Context, newThread models.Thread) (models.Thread, error) {
+	start := time.Now()
+	result, err := l.next.UpdateThread(ctx, newThread)
+	l.observe(ctx, "UpdateThread", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) GetThreads(ctx context.Context, slug string, limit int, since string, desc bool) ([]models.Thread, error) {
+	start := time.Now()
+	result, err := l.next.GetThreads(ctx, slug, limit, since, desc)
+	l.observe(ctx, "GetThreads", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) CheckThreadExists(ctx context.Context, slug string) (bool, error) {
+	start := time.Now()
+	result, err := l.next.CheckThreadExists(ctx, slug)
+	l.observe(ctx, "CheckThreadExists", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) GetThreadBySlug(ctx context.Context, slug string) (models.Thread, error) {
+	start := time.Now()
+	result, err := l.next.GetThreadBySlug(ctx, slug)
+	l.observe(ctx, "GetThreadBySlug", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) GetThreadByID(ctx context.Context, id int) (models.Thread, error) {
+	start := time.Now()
+	result, err := l.next.GetThreadByID(ctx, id)
+	l.observe(ctx, "GetThreadByID", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) GetThreadIDBySlug(ctx context.Context, slug string) (int, error) {
+	start := time.Now()
+	result, err := l.next.GetThreadIDBySlug(ctx, slug)
+	l.observe(ctx, "GetThreadIDBySlug", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) AddModerator(ctx context.Context, slug, nickname string) error {
+	start := time.Now()
+	err := l.next.AddModerator(ctx, slug, nickname)
+	l.observe(ctx, "AddModerator", start, err)
+	return err
+}
+
+func (l *loggingForumRepository) RemoveModerator(ctx context.Context, slug, nickname string) error {
+	start := time.Now()
+	err := l.next.RemoveModerator(ctx, slug, nickname)
+	l.observe(ctx, "RemoveModerator", start, err)
+	return err
+}
+
+func (l *loggingForumRepository) IsModerator(ctx context.Context, slug, nickname string) (bool, error) {
+	start := time.Now()
+	result, err := l.next.IsModerator(ctx, slug, nickname)
+	l.observe(ctx, "IsModerator", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) GetThreadSlugByID(ctx context.Context, id int) (string, error) {
+	start := time.Now()
+	result, err := l.next.GetThreadSlugByID(ctx, id)
+	l.observe(ctx, "GetThreadSlugByID", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) AddPosts(ctx context.Context, posts []models.Post, threadID int) ([]models.Post, error) {
+	start := time.Now()
+	result, err := l.next.AddPosts(ctx, posts, threadID)
+	l.observe(ctx, "AddPosts", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) GetPosts(ctx context.Context, postSlugOrId models.Thread, limit, since int, sort string, desc bool) ([]models.Post, error) {
+	start := time.Now()
+	result, err := l.next.GetPosts(ctx, postSlugOrId, limit, since, sort, desc)
+	l.observe(ctx, "GetPosts", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) GetPost(ctx context.Context, id int, related []string) (map[string]interface{}, error) {
+	start := time.Now()
+	result, err := l.next.GetPost(ctx, id, related)
+	l.observe(ctx, "GetPost", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) UpdatePost(ctx context.Context, newPost models.Post) (models.Post, error) {
+	start := time.Now()
+	result, err := l.next.UpdatePost(ctx, newPost)
+	l.observe(ctx, "UpdatePost", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) DeletePost(ctx context.Context, id int) (models.Post, error) {
+	start := time.Now()
+	result, err := l.next.DeletePost(ctx, id)
+	l.observe(ctx, "DeletePost", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) PurgeDeletedPosts(ctx context.Context) (int64, error) {
+	start := time.Now()
+	result, err := l.next.PurgeDeletedPosts(ctx)
+	l.observe(ctx, "PurgeDeletedPosts", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) Search(ctx context.Context, query models.SearchQuery) ([]models.SearchResult, error) {
+	start := time.Now()
+	result, err := l.next.Search(ctx, query)
+	l.observe(ctx, "Search", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) AddVote(ctx context.Context, vote models.Vote) error {
+	start := time.Now()
+	err := l.next.AddVote(ctx, vote)
+	l.observe(ctx, "AddVote", start, err)
+	return err
+}
+
+func (l *loggingForumRepository) UpdateVote(ctx context.Context, vote models.Vote) error {
+	start := time.Now()
+	err := l.next.UpdateVote(ctx, vote)
+	l.observe(ctx, "UpdateVote", start, err)
+	return err
+}
+
+func (l *loggingForumRepository) GetServiceStatus(ctx context.Context) (map[string]int, error) {
+	start := time.Now()
+	result, err := l.next.GetServiceStatus(ctx)
+	l.observe(ctx, "GetServiceStatus", start, err)
+	return result, err
+}
+
+func (l *loggingForumRepository) ClearDatabase(ctx context.Context) error {
+	start := time.Now()
+	err := l.next.ClearDatabase(ctx)
+	l.observe(ctx, "ClearDatabase", start, err)
+	return err
+}
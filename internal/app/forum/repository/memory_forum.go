@@ -0,0 +1,786 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/forum"
+	"DbProjectForum/internal/app/forum/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/memstore"
+	"context"
+	"github.com/go-openapi/strfmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type memoryForumRepository struct {
+	store *memstore.Store
+}
+
+// NewMemoryForumRepository creates a forum repository that keeps its data in
+// store and follows the behaviour of the Postgres schema, including the
+// counters maintained there by triggers.
+func NewMemoryForumRepository(store *memstore.Store) forum.Repository {
+	return &memoryForumRepository{store: store}
+}
+
+func threadModel(thread *memstore.Thread) models.Thread {
+	threadObj := thread.Thread
+	threadObj.Created = strfmt.DateTime(thread.CreatedAt.UTC()).String()
+	return threadObj
+}
+
+// postModel converts a stored post to the API model, hiding the message of
+// deleted posts.
+func postModel(post *memstore.Post) models.Post {
+	postObj := post.Post
+	postObj.Created = strfmt.DateTime(post.CreatedAt.UTC()).String()
+	_ = postObj.Path.Set(post.PathIDs)
+	if postObj.IsDeleted {
+		postObj.Message = ""
+	}
+	return postObj
+}
+
+func parseTime(value string) (time.Time, error) {
+	created, err := strfmt.ParseDateTime(value)
+	if err != nil {
+		return time.Time{}, apperrors.Validation("Invalid timestamp: %s", value)
+	}
+	return time.Time(created), nil
+}
+
+// comparePaths orders materialized paths the way Postgres compares arrays.
+func comparePaths(a, b []int64) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+func (m *memoryForumRepository) Add(ctx context.Context, forum models.Forum) (models.Forum, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	userObj, ok := m.store.Users[memstore.Key(forum.User)]
+	if !ok {
+		return models.Forum{}, apperrors.NotFound("Can't find user by nickname: %s", forum.User)
+	}
+	if _, ok := m.store.Forums[memstore.Key(forum.Slug)]; ok {
+		return models.Forum{}, apperrors.Conflict("Forum with slug %s already exists", forum.Slug)
+	}
+
+	forumObj := models.Forum{
+		Slug:  forum.Slug,
+		Title: forum.Title,
+		User:  userObj.Nickname,
+	}
+	m.store.Forums[memstore.Key(forum.Slug)] = &forumObj
+	return forumObj, nil
+}
+
+func (m *memoryForumRepository) GetBySlug(ctx context.Context, slug string) (models.Forum, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	forumObj, ok := m.store.Forums[memstore.Key(slug)]
+	if !ok {
+		return models.Forum{}, apperrors.NotFound("Can't find forum with slug: %s", slug)
+	}
+	return *forumObj, nil
+}
+
+func (m *memoryForumRepository) AddThread(ctx context.Context, thread models.Thread) (models.Thread, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	forumObj, ok := m.store.Forums[memstore.Key(thread.Forum)]
+	if !ok {
+		return models.Thread{}, apperrors.NotFound("Can't find forum with slug: %s", thread.Forum)
+	}
+	if thread.Slug.String == "" {
+		thread.Slug.Valid = false
+	}
+	if _, ok := m.store.ThreadBySlug(thread.Slug.String); ok && thread.Slug.Valid {
+		return models.Thread{}, apperrors.Conflict("Thread with slug %s already exists", thread.Slug.String)
+	}
+	if _, ok := m.store.Users[memstore.Key(thread.Author)]; !ok {
+		return models.Thread{}, apperrors.NotFound("Can't find thread author by nickname: %s", thread.Author)
+	}
+
+	var created time.Time
+	if thread.Created != "" {
+		var err error
+		if created, err = parseTime(thread.Created); err != nil {
+			return models.Thread{}, err
+		}
+	}
+
+	stored := &memstore.Thread{Thread: thread, CreatedAt: created}
+	stored.Id = int32(len(m.store.Threads) + 1)
+	stored.Forum = forumObj.Slug
+	stored.Votes = 0
+	m.store.Threads = append(m.store.Threads, stored)
+
+	forumObj.Threads++
+	m.store.AddUserToForum(thread.Author, forumObj.Slug)
+	return threadModel(stored), nil
+}
+
+func (m *memoryForumRepository) GetThreads(ctx context.Context, slug string, limit int, since string,
+	desc bool) ([]models.Thread, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	var sinceTime time.Time
+	if since != "" {
+		var err error
+		if sinceTime, err = parseTime(since); err != nil {
+			return nil, err
+		}
+	}
+
+	var threads []*memstore.Thread
+	for _, thread := range m.store.Threads {
+		if thread == nil || memstore.Key(thread.Forum) != memstore.Key(slug) {
+			continue
+		}
+		if since != "" && desc && thread.CreatedAt.After(sinceTime) {
+			continue
+		}
+		if since != "" && !desc && thread.CreatedAt.Before(sinceTime) {
+			continue
+		}
+		threads = append(threads, thread)
+	}
+
+	sort.SliceStable(threads, func(i, j int) bool {
+		if desc {
+			return threads[i].CreatedAt.After(threads[j].CreatedAt)
+		}
+		return threads[i].CreatedAt.Before(threads[j].CreatedAt)
+	})
+	if limit > 0 && len(threads) > limit {
+		threads = threads[:limit]
+	}
+
+	data := make([]models.Thread, 0, len(threads))
+	for _, thread := range threads {
+		data = append(data, threadModel(thread))
+	}
+	return data, nil
+}
+
+func (m *memoryForumRepository) CheckThreadExists(ctx context.Context, slug string) (bool, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	for _, thread := range m.store.Threads {
+		if thread != nil && memstore.Key(thread.Forum) == memstore.Key(slug) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryForumRepository) GetThreadBySlug(ctx context.Context, slug string) (models.Thread, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	thread, ok := m.store.ThreadBySlug(slug)
+	if !ok {
+		return models.Thread{}, apperrors.NotFound("Can't find thread by slug: %s", slug)
+	}
+	return threadModel(thread), nil
+}
+
+func (m *memoryForumRepository) GetThreadByID(ctx context.Context, id int) (models.Thread, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	thread, ok := m.store.Thread(id)
+	if !ok {
+		return models.Thread{}, apperrors.NotFound("Can't find thread by id: %d", id)
+	}
+	return threadModel(thread), nil
+}
+
+func (m *memoryForumRepository) GetThreadIDBySlug(ctx context.Context, slug string) (int, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	thread, ok := m.store.ThreadBySlug(slug)
+	if !ok {
+		return 0, apperrors.NotFound("Can't find thread by slug: %s", slug)
+	}
+	return int(thread.Id), nil
+}
+
+func (m *memoryForumRepository) AddModerator(ctx context.Context, slug, nickname string) error {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	forumKey, userKey := memstore.Key(slug), memstore.Key(nickname)
+	_, forumFound := m.store.Forums[forumKey]
+	_, userFound := m.store.Users[userKey]
+	if !forumFound || !userFound {
+		return apperrors.NotFound("Can't find forum %s or user %s", slug, nickname)
+	}
+	if m.store.Moderators[forumKey][userKey] {
+		return apperrors.Conflict("User %s already moderates forum %s", nickname, slug)
+	}
+	if m.store.Moderators[forumKey] == nil {
+		m.store.Moderators[forumKey] = make(map[string]bool)
+	}
+	m.store.Moderators[forumKey][userKey] = true
+	return nil
+}
+
+func (m *memoryForumRepository) RemoveModerator(ctx context.Context, slug, nickname string) error {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	forumKey, userKey := memstore.Key(slug), memstore.Key(nickname)
+	if !m.store.Moderators[forumKey][userKey] {
+		return apperrors.NotFound("User %s doesn't moderate forum %s", nickname, slug)
+	}
+	delete(m.store.Moderators[forumKey], userKey)
+	return nil
+}
+
+func (m *memoryForumRepository) IsModerator(ctx context.Context, slug, nickname string) (bool, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	forumKey, userKey := memstore.Key(slug), memstore.Key(nickname)
+	if forumObj, ok := m.store.Forums[forumKey]; ok && memstore.Key(forumObj.User) == userKey {
+		return true, nil
+	}
+	return m.store.Moderators[forumKey][userKey], nil
+}
+
+func (m *memoryForumRepository) GetThreadSlugByID(ctx context.Context, id int) (string, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	thread, ok := m.store.Thread(id)
+	if !ok {
+		return "", apperrors.NotFound("Can't find thread by id: %d", id)
+	}
+	return thread.Slug.String, nil
+}
+
+func (m *memoryForumRepository) AddPosts(ctx context.Context, posts []models.Post, threadID int) ([]models.Post, error) {
+	data := make([]models.Post, 0, 0)
+	if len(posts) == 0 {
+		return data, nil
+	}
+
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	thread, ok := m.store.Thread(threadID)
+	if !ok {
+		return data, apperrors.NotFound("Can't find thread by id: %d", threadID)
+	}
+
+	for i, post := range posts {
+		if _, ok := m.store.Users[memstore.Key(post.Author)]; !ok {
+			return data, apperrors.NotFound("Can't find author %s of post %d", post.Author, i)
+		}
+	}
+	for i, post := range posts {
+		if post.Parent.Int64 == 0 {
+			continue
+		}
+		parent, ok := m.store.Post(post.Parent.Int64)
+		if !ok || parent.Thread != thread.Id {
+			return data, apperrors.New(apperrors.ErrParentInOtherThread,
+				"Parent post %d of post %d is not in thread %d", post.Parent.Int64, i, threadID)
+		}
+	}
+
+	created := time.Now()
+	forumObj := m.store.Forums[memstore.Key(thread.Forum)]
+	for _, post := range posts {
+		stored := &memstore.Post{Post: post, CreatedAt: created}
+		stored.Id = int64(len(m.store.Posts) + 1)
+		stored.Forum = forumObj.Slug
+		stored.Thread = thread.Id
+		stored.IsEdited = false
+		if post.Parent.Int64 == 0 {
+			stored.Parent.Valid = false
+			stored.PathIDs = []int64{stored.Id}
+		} else {
+			parent, _ := m.store.Post(post.Parent.Int64)
+			stored.PathIDs = append(append([]int64{}, parent.PathIDs...), stored.Id)
+		}
+		m.store.Posts = append(m.store.Posts, stored)
+
+		m.store.AddUserToForum(post.Author, forumObj.Slug)
+		data = append(data, postModel(stored))
+	}
+	forumObj.Posts += int64(len(data))
+
+	return data, nil
+}
+
+func (m *memoryForumRepository) AddVote(ctx context.Context, vote models.Vote) error {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	if _, ok := m.store.Users[memstore.Key(vote.Nickname)]; !ok {
+		return apperrors.NotFound("Can't find user by nickname: %s", vote.Nickname)
+	}
+	thread, ok := m.store.Thread(int(vote.IdThread))
+	if !ok {
+		return apperrors.NotFound("Can't find thread by id: %d", vote.IdThread)
+	}
+
+	key := memstore.VoteKey{Nickname: memstore.Key(vote.Nickname), Thread: vote.IdThread}
+	if _, ok := m.store.Votes[key]; ok {
+		return apperrors.Conflict("User %s has already voted for thread %d", vote.Nickname, vote.IdThread)
+	}
+	m.store.Votes[key] = vote.Voice
+	thread.Votes += vote.Voice
+	return nil
+}
+
+func (m *memoryForumRepository) UpdateVote(ctx context.Context, vote models.Vote) error {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	key := memstore.VoteKey{Nickname: memstore.Key(vote.Nickname), Thread: vote.IdThread}
+	voice, ok := m.store.Votes[key]
+	if !ok {
+		return nil
+	}
+	thread, _ := m.store.Thread(int(vote.IdThread))
+	thread.Votes += vote.Voice - voice
+	m.store.Votes[key] = vote.Voice
+	return nil
+}
+
+func (m *memoryForumRepository) threadPosts(threadID int) []*memstore.Post {
+	var posts []*memstore.Post
+	for _, post := range m.store.Posts {
+		if post != nil && int(post.Thread) == threadID {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+func (m *memoryForumRepository) getPostsFlat(threadID, limit, since int, desc bool) []*memstore.Post {
+	var posts []*memstore.Post
+	for _, post := range m.threadPosts(threadID) {
+		if since > 0 && desc && post.Id >= int64(since) {
+			continue
+		}
+		if since > 0 && !desc && post.Id <= int64(since) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		if desc {
+			return posts[i].Id > posts[j].Id
+		}
+		return posts[i].Id < posts[j].Id
+	})
+	if limit > 0 && len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts
+}
+
+func (m *memoryForumRepository) getPostsTree(threadID, limit, since int, desc bool) []*memstore.Post {
+	var sincePost *memstore.Post
+	if since != 0 {
+		var ok bool
+		if sincePost, ok = m.store.Post(int64(since)); !ok {
+			return nil
+		}
+	}
+
+	var posts []*memstore.Post
+	for _, post := range m.threadPosts(threadID) {
+		if sincePost != nil && desc && comparePaths(post.PathIDs, sincePost.PathIDs) >= 0 {
+			continue
+		}
+		if sincePost != nil && !desc && comparePaths(post.PathIDs, sincePost.PathIDs) <= 0 {
+			continue
+		}
+		posts = append(posts, post)
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		if desc {
+			return comparePaths(posts[i].PathIDs, posts[j].PathIDs) > 0
+		}
+		return comparePaths(posts[i].PathIDs, posts[j].PathIDs) < 0
+	})
+	if limit > 0 && len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts
+}
+
+func (m *memoryForumRepository) getPostsParentTree(threadID, limit, since int, desc bool) []*memstore.Post {
+	var sinceRoot int64
+	if since != 0 {
+		sincePost, ok := m.store.Post(int64(since))
+		if !ok {
+			return nil
+		}
+		sinceRoot = sincePost.PathIDs[0]
+	}
+
+	threadPosts := m.threadPosts(threadID)
+	var roots []int64
+	for _, post := range threadPosts {
+		if post.Parent.Valid {
+			continue
+		}
+		if sinceRoot != 0 && desc && post.Id >= sinceRoot {
+			continue
+		}
+		if sinceRoot != 0 && !desc && post.Id <= sinceRoot {
+			continue
+		}
+		roots = append(roots, post.Id)
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		if desc {
+			return roots[i] > roots[j]
+		}
+		return roots[i] < roots[j]
+	})
+	if limit > 0 && len(roots) > limit {
+		roots = roots[:limit]
+	}
+
+	selected := make(map[int64]bool, len(roots))
+	for _, root := range roots {
+		selected[root] = true
+	}
+	var posts []*memstore.Post
+	for _, post := range threadPosts {
+		if selected[post.PathIDs[0]] {
+			posts = append(posts, post)
+		}
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		a, b := posts[i].PathIDs, posts[j].PathIDs
+		if desc && a[0] != b[0] {
+			return a[0] > b[0]
+		}
+		return comparePaths(a, b) < 0
+	})
+	return posts
+}
+
+func (m *memoryForumRepository) GetPosts(ctx context.Context, postSlugOrId models.Thread, limit, since int,
+	sort string, desc bool) ([]models.Post, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	threadId := int(postSlugOrId.Id)
+	if postSlugOrId.Id <= 0 {
+		thread, ok := m.store.ThreadBySlug(postSlugOrId.Slug.String)
+		if !ok {
+			return nil, apperrors.NotFound("Can't find thread by slug: %s", postSlugOrId.Slug.String)
+		}
+		threadId = int(thread.Id)
+	}
+
+	var posts []*memstore.Post
+	switch sort {
+	case "flat":
+		posts = m.getPostsFlat(threadId, limit, since, desc)
+	case "tree":
+		posts = m.getPostsTree(threadId, limit, since, desc)
+	case "parent_tree":
+		posts = m.getPostsParentTree(threadId, limit, since, desc)
+	default:
+		return nil, apperrors.Validation("Unknown sort type: %s", sort)
+	}
+
+	var data []models.Post
+	for _, post := range posts {
+		data = append(data, postModel(post))
+	}
+	return data, nil
+}
+
+func (m *memoryForumRepository) GetPost(ctx context.Context, id int, related []string) (map[string]interface{}, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	post, ok := m.store.Post(int64(id))
+	if !ok {
+		return nil, apperrors.NotFound("Can't find post with id: %d", id)
+	}
+
+	returnMap := map[string]interface{}{
+		"post": postModel(post),
+	}
+
+	for _, relatedObj := range related {
+		switch relatedObj {
+		case "user":
+			returnMap["author"] = *m.store.Users[memstore.Key(post.Author)]
+		case "thread":
+			thread, _ := m.store.Thread(int(post.Thread))
+			returnMap["thread"] = threadModel(thread)
+		case "forum":
+			returnMap["forum"] = *m.store.Forums[memstore.Key(post.Forum)]
+		}
+	}
+
+	return returnMap, nil
+}
+
+func (m *memoryForumRepository) UpdatePost(ctx context.Context, newPost models.Post) (models.Post, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	post, ok := m.store.Post(newPost.Id)
+	if !ok {
+		return models.Post{}, apperrors.NotFound("Can't find post with id: %d", newPost.Id)
+	}
+
+	if post.IsDeleted {
+		return models.Post{}, apperrors.Conflict("Post %d is deleted", newPost.Id)
+	}
+
+	if newPost.Message != "" && newPost.Message != post.Message {
+		post.Message = newPost.Message
+		post.IsEdited = true
+	}
+	return postModel(post), nil
+}
+
+func (m *memoryForumRepository) DeletePost(ctx context.Context, id int) (models.Post, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	post, ok := m.store.Post(int64(id))
+	if !ok {
+		return models.Post{}, apperrors.NotFound("Can't find post with id: %d", id)
+	}
+	if post.IsDeleted {
+		return models.Post{}, apperrors.Conflict("Post %d is already deleted", id)
+	}
+
+	post.IsDeleted = true
+	if forumObj, ok := m.store.Forums[memstore.Key(post.Forum)]; ok {
+		forumObj.Posts--
+	}
+	return postModel(post), nil
+}
+
+func (m *memoryForumRepository) PurgeDeletedPosts(ctx context.Context) (int64, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	// A tombstone goes once no post below it, itself included, is alive.
+	alive := make(map[int64]bool)
+	for _, post := range m.store.Posts {
+		if post != nil && !post.IsDeleted {
+			for _, id := range post.PathIDs {
+				alive[id] = true
+			}
+		}
+	}
+
+	var purged int64
+	for _, post := range m.store.Posts {
+		if post != nil && post.IsDeleted && !alive[post.Id] {
+			m.store.DeletePost(post.Id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// searchTerms splits text into lower case words the way the 'simple' text
+// search configuration does.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchText ranks text against terms by the share of its words that match and
+// highlights them, or returns false unless every term occurs in text.
+func matchText(text string, terms []string) (float32, string, bool) {
+	words := searchTerms(text)
+	found := make(map[string]bool, len(terms))
+	hits := 0
+	for _, word := range words {
+		for _, term := range terms {
+			if word == term {
+				found[term] = true
+				hits++
+			}
+		}
+	}
+	if len(terms) == 0 || len(found) != len(terms) {
+		return 0, "", false
+	}
+
+	snippet := unmarker.Replace(text)
+	for term := range found {
+		snippet = highlight(snippet, term)
+	}
+	return float32(hits) / float32(len(words)), snippetHTML(snippet), true
+}
+
+// highlight marks the whole-word, case-insensitive occurrences of term.
+func highlight(text, term string) string {
+	var result strings.Builder
+	runes := []rune(text)
+	start := -1
+	flush := func(end int) {
+		word := string(runes[start:end])
+		if strings.ToLower(word) == term {
+			word = matchStart + word + matchStop
+		}
+		result.WriteString(word)
+		start = -1
+	}
+	for i, r := range runes {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord {
+			if start >= 0 {
+				flush(i)
+			}
+			result.WriteRune(r)
+		}
+	}
+	if start >= 0 {
+		flush(len(runes))
+	}
+	return result.String()
+}
+
+func searchMatches(query models.SearchQuery, forumSlug, author string) bool {
+	if query.Forum != "" && memstore.Key(forumSlug) != memstore.Key(query.Forum) {
+		return false
+	}
+	return query.Author == "" || memstore.Key(author) == memstore.Key(query.Author)
+}
+
+func (m *memoryForumRepository) Search(ctx context.Context, query models.SearchQuery) ([]models.SearchResult, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range searchTerms(query.Text) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	results := make([]models.SearchResult, 0)
+
+	if query.Type != models.SearchPost {
+		for _, thread := range m.store.Threads {
+			if thread == nil {
+				continue
+			}
+			if !searchMatches(query, thread.Forum, thread.Author) {
+				continue
+			}
+			rank, snippet, ok := matchText(thread.Title+" "+thread.Message, terms)
+			if !ok {
+				continue
+			}
+			threadObj := threadModel(thread)
+			results = append(results, models.SearchResult{
+				Type:    models.SearchThread,
+				Rank:    rank,
+				Snippet: snippet,
+				Thread:  &threadObj,
+			})
+		}
+	}
+
+	if query.Type != models.SearchThread {
+		for _, post := range m.store.Posts {
+			if post == nil || post.IsDeleted {
+				continue
+			}
+			if !searchMatches(query, post.Forum, post.Author) {
+				continue
+			}
+			rank, snippet, ok := matchText(post.Message, terms)
+			if !ok {
+				continue
+			}
+			postObj := postModel(post)
+			results = append(results, models.SearchResult{
+				Type:    models.SearchPost,
+				Rank:    rank,
+				Snippet: snippet,
+				Post:    &postObj,
+			})
+		}
+	}
+
+	return mergeResults(results, query), nil
+}
+
+func (m *memoryForumRepository) UpdateThread(ctx context.Context, newThread models.Thread) (models.Thread, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	var thread *memstore.Thread
+	var ok bool
+	if newThread.Id > 0 {
+		if thread, ok = m.store.Thread(int(newThread.Id)); !ok {
+			return models.Thread{}, apperrors.NotFound("Can't find thread by id: %d", newThread.Id)
+		}
+	} else if thread, ok = m.store.ThreadBySlug(newThread.Slug.String); !ok {
+		return models.Thread{}, apperrors.NotFound("Can't find thread by slug: %s", newThread.Slug.String)
+	}
+
+	if newThread.Message != "" {
+		thread.Message = newThread.Message
+	}
+	if newThread.Title != "" {
+		thread.Title = newThread.Title
+	}
+	return threadModel(thread), nil
+}
+
+func (m *memoryForumRepository) GetServiceStatus(ctx context.Context) (map[string]int, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	return map[string]int{
+		"forum":  len(m.store.Forums),
+		"post":   m.store.PostCount(),
+		"thread": m.store.ThreadCount(),
+		"user":   len(m.store.Users),
+	}, nil
+}
+
+func (m *memoryForumRepository) ClearDatabase(ctx context.Context) error {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	m.store.Reset()
+	return nil
+}
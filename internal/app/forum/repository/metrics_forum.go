@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/forum"
+	"DbProjectForum/internal/app/forum/models"
+	"DbProjectForum/internal/pkg/metrics"
+	"context"
+	"time"
+)
+
+// metricsForumRepository records the latency of every repository call.
+type metricsForumRepository struct {
+	next forum.Repository
+}
+
+func NewMetricsForumRepository(next forum.Repository) forum.Repository {
+	return &metricsForumRepository{next: next}
+}
+
+func (m *metricsForumRepository) observe(method string, start time.Time, err error) {
+	metrics.RepositoryDuration.WithLabelValues("forum", method, metrics.Outcome(err)).
+		Observe(time.Since(start).Seconds())
+}
+
+func (m *metricsForumRepository) Add(ctx context.Context, forum models.Forum) (models.Forum, error) {
+	start := time.Now()
+	result, err := m.next.Add(ctx, forum)
+	m.observe("Add", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) GetBySlug(ctx context.Context, slug string) (models.Forum, error) {
+	start := time.Now()
+	result, err := m.next.GetBySlug(ctx, slug)
+	m.observe("GetBySlug", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) AddThread(ctx context.Context, thread models.Thread) (models.Thread, error) {
+	start := time.Now()
+	result, err := m.next.AddThread(ctx, thread)
+	m.observe("AddThread", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) UpdateThread(ctx context.Context, newThread models.Thread) (models.Thread, error) {
+	start := time.Now()
+	result, err := m.next.UpdateThread(ctx, newThread)
+	m.observe("UpdateThread", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) GetThreads(ctx context.Context, slug string, limit int, since string, desc bool) ([]models.Thread, error) {
+	start := time.Now()
+	result, err := m.next.GetThreads(ctx, slug, limit, since, desc)
+	m.observe("GetThreads", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) CheckThreadExists(ctx context.Context, slug string) (bool, error) {
+	start := time.Now()
+	result, err := m.next.CheckThreadExists(ctx, slug)
+	m.observe("CheckThreadExists", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) GetThreadBySlug(ctx context.Context, slug string) (models.Thread, error) {
+	start := time.Now()
+	result, err := m.next.GetThreadBySlug(ctx, slug)
+	m.observe("GetThreadBySlug", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) GetThreadByID(ctx context.Context, id int) (models.Thread, error) {
+	start := time.Now()
+	result, err := m.next.GetThreadByID(ctx, id)
+	m.observe("GetThreadByID", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) GetThreadIDBySlug(ctx context.Context, slug string) (int, error) {
+	start := time.Now()
+	result, err := m.next.GetThreadIDBySlug(ctx, slug)
+	m.observe("GetThreadIDBySlug", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) AddModerator(ctx context.Context, slug, nickname string) error {
+	start := time.Now()
+	err := m.next.AddModerator(ctx, slug, nickname)
+	m.observe("AddModerator", start, err)
+	return err
+}
+
+func (m *metricsForumRepository) RemoveModerator(ctx context.Context, slug, nickname string) error {
+	start := time.Now()
+	err := m.next.RemoveModerator(ctx, slug, nickname)
+	m.observe("RemoveModerator", start, err)
+	return err
+}
+
+func (m *metricsForumRepository) IsModerator(ctx context.Context, slug, nickname string) (bool, error) {
+	start := time.Now()
+	result, err := m.next.IsModerator(ctx, slug, nickname)
+	m.observe("IsModerator", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) GetThreadSlugByID(ctx context.Context, id int) (string, error) {
+	start := time.Now()
+	result, err := m.next.GetThreadSlugByID(ctx, id)
+	m.observe("GetThreadSlugByID", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) AddPosts(ctx context.Context, posts []models.Post, threadID int) ([]models.Post, error) {
+	start := time.Now()
+	result, err := m.next.AddPosts(ctx, posts, threadID)
+	m.observe("AddPosts", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) GetPosts(ctx context.Context, postSlugOrId models.Thread, limit, since int, sort string, desc bool) ([]models.Post, error) {
+	start := time.Now()
+	result, err := m.next.GetPosts(ctx, postSlugOrId, limit, since, sort, desc)
+	m.observe("GetPosts", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) GetPost(ctx context.Context, id int, related []string) (map[string]interface{}, error) {
+	start := time.Now()
+	result, err := m.next.GetPost(ctx, id, related)
+	m.observe("GetPost", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) UpdatePost(ctx context.Context, newPost models.Post) (models.Post, error) {
+	start := time.Now()
+	result, err := m.next.UpdatePost(ctx, newPost)
+	m.observe("UpdatePost", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) DeletePost(ctx context.Context, id int) (models.Post, error) {
+	start := time.Now()
+	result, err := m.next.DeletePost(ctx, id)
+	m.observe("DeletePost", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) PurgeDeletedPosts(ctx context.Context) (int64, error) {
+	start := time.Now()
+	result, err := m.next.PurgeDeletedPosts(ctx)
+	m.observe("PurgeDeletedPosts", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) Search(ctx context.Context, query models.SearchQuery) ([]models.SearchResult, error) {
+	start := time.Now()
+	result, err := m.next.Search(ctx, query)
+	m.observe("Search", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) AddVote(ctx context.Context, vote models.Vote) error {
+	start := time.Now()
+	err := m.next.AddVote(ctx, vote)
+	m.observe("AddVote", start, err)
+	return err
+}
+
+func (m *metricsForumRepository) UpdateVote(ctx context.Context, vote models.Vote) error {
+	start := time.Now()
+	err := m.next.UpdateVote(ctx, vote)
+	m.observe("UpdateVote", start, err)
+	return err
+}
+
+func (m *metricsForumRepository) GetServiceStatus(ctx context.Context) (map[string]int, error) {
+	start := time.Now()
+	result, err := m.next.GetServiceStatus(ctx)
+	m.observe("GetServiceStatus", start, err)
+	return result, err
+}
+
+func (m *metricsForumRepository) ClearDatabase(ctx context.Context) error {
+	start := time.Now()
+	err := m.next.ClearDatabase(ctx)
+	m.observe("ClearDatabase", start, err)
+	return err
+}
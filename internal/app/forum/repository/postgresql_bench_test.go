@@ -0,0 +1,89 @@
+package repository_test
+
+import (
+	"DbProjectForum/internal/app/forum/models"
+	"DbProjectForum/internal/app/storage"
+	userModels "DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/testdb"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// BenchmarkPostgres runs the hot queries through prepared statements and
+// through the simple protocol, which is what pgbouncer deployments use:
+//
+//	FORUM_TEST_POSTGRES=1 go test -run '^$' -bench Postgres ./internal/app/forum/repository
+func BenchmarkPostgres(b *testing.B) {
+	for _, protocol := range []struct {
+		name   string
+		simple bool
+	}{
+		{"prepared", false},
+		{"simple", true},
+	} {
+		b.Run(protocol.name, func(b *testing.B) {
+			repos := testdb.Postgres(b, protocol.simple)
+			ctx := context.Background()
+			thread := benchmarkThread(b, repos)
+
+			b.Run("GetBySlug", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := repos.Forum.GetBySlug(ctx, thread.Forum); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run("GetThreadByID", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := repos.Forum.GetThreadByID(ctx, int(thread.Id)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run("GetByNick", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := repos.User.GetByNick(ctx, thread.Author); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run("AddPosts", func(b *testing.B) {
+				posts := make([]models.Post, 10)
+				for i := range posts {
+					posts[i] = models.Post{Author: thread.Author, Message: fmt.Sprintf("post %d", i)}
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := repos.Forum.AddPosts(ctx, posts, int(thread.Id)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func benchmarkThread(b *testing.B, repos storage.Repositories) models.Thread {
+	b.Helper()
+	ctx := context.Background()
+
+	author := userModels.User{Nickname: "bench", FullName: "Bench", Email: "bench@example.com"}
+	if err := repos.User.Add(ctx, author); err != nil {
+		b.Fatal(err)
+	}
+	forumObj, err := repos.Forum.Add(ctx, models.Forum{Slug: "bench", Title: "Bench", User: author.Nickname})
+	if err != nil {
+		b.Fatal(err)
+	}
+	thread, err := repos.Forum.AddThread(ctx, models.Thread{
+		Author:  author.Nickname,
+		Forum:   forumObj.Slug,
+		Message: "Benchmark",
+		Title:   "Benchmark",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return thread
+}
@@ -4,34 +4,50 @@ import (
 	"DbProjectForum/internal/app/forum"
 	"DbProjectForum/internal/app/forum/models"
 	"DbProjectForum/internal/app/user"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/sqlbuilder"
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/go-openapi/strfmt"
 	"github.com/jackc/pgx"
+	"sort"
 	"strings"
 	"time"
 )
 
 type postgresForumRepository struct {
-	conn     *pgx.ConnPool
-	userRepo user.Repository
+	conn          *pgx.ConnPool
+	userRepo      user.Repository
+	prepared      bool
+	copyThreshold int
 }
 
-func NewPostgresForumRepository(conn *pgx.ConnPool, repository user.Repository) forum.Repository {
+// NewPostgresForumRepository creates the forum repository. When prepared is
+// set, hot queries run through the statements registered by PrepareStatements.
+// Batches of at least copyThreshold posts are loaded with COPY.
+func NewPostgresForumRepository(conn *pgx.ConnPool, repository user.Repository, prepared bool,
+	copyThreshold int) forum.Repository {
 	return &postgresForumRepository{
-		conn:     conn,
-		userRepo: repository,
+		conn:          conn,
+		userRepo:      repository,
+		prepared:      prepared,
+		copyThreshold: copyThreshold,
 	}
 }
 
-func (p *postgresForumRepository) Add(forum models.Forum) (models.Forum, error) {
-	query := `INSERT INTO forum(
-    "user",
-    slug,
-    title)
-	VALUES ($1, $2, $3) RETURNING *`
+func (p *postgresForumRepository) statement(name string) string {
+	if p.prepared {
+		return name
+	}
+	return statements[name]
+}
+
+func (p *postgresForumRepository) Add(ctx context.Context, forum models.Forum) (models.Forum, error) {
+	query := p.statement(insertForum)
 
-	userObj, err := p.userRepo.GetByNick(forum.User)
+	userObj, err := p.userRepo.GetByNick(ctx, forum.User)
 	if err != nil {
 		return models.Forum{}, err
 	}
@@ -39,30 +55,29 @@ func (p *postgresForumRepository) Add(forum models.Forum) (models.Forum, error)
 	var forumObj models.Forum
 	err = p.conn.QueryRow(query, userObj.Nickname, forum.Slug, forum.Title).Scan(&forumObj.User, &forumObj.Posts, &forumObj.Slug, &forumObj.Threads, &forumObj.Title)
 	//err = p.conn.Get(&forumObj, query, userObj.Nickname, forum.Slug, forum.Title)
-	return forumObj, err
+	if errors.Is(apperrors.FromPg(err), apperrors.ErrConflict) {
+		return forumObj, apperrors.Conflict("Forum with slug %s already exists", forum.Slug)
+	}
+	return forumObj, apperrors.FromPg(err)
 }
 
-func (p *postgresForumRepository) GetBySlug(slug string) (models.Forum, error) {
-	query := `SELECT * FROM forum WHERE LOWER(slug)=LOWER($1)`
+func (p *postgresForumRepository) GetBySlug(ctx context.Context, slug string) (models.Forum, error) {
+	query := p.statement(forumBySlug)
 
 	var forumObj models.Forum
 	err := p.conn.QueryRow(query, slug).Scan(&forumObj.User, &forumObj.Posts, &forumObj.Slug, &forumObj.Threads, &forumObj.Title)
 	//err := p.conn.Get(&forumObj, query, slug)
+	if err == pgx.ErrNoRows {
+		return forumObj, apperrors.NotFound("Can't find forum with slug: %s", slug)
+	}
 
-	return forumObj, err
+	return forumObj, apperrors.FromPg(err)
 }
 
-func (p *postgresForumRepository) AddThread(thread models.Thread) (models.Thread, error) {
-	query := `INSERT INTO thread(
-    slug,
-    author,
-    created,
-    message,
-    title,
-	forum)
-	VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6) RETURNING *`
+func (p *postgresForumRepository) AddThread(ctx context.Context, thread models.Thread) (models.Thread, error) {
+	query := p.statement(insertThread)
 
-	forumObj, err := p.GetBySlug(thread.Forum)
+	forumObj, err := p.GetBySlug(ctx, thread.Forum)
 	if err != nil {
 		return models.Thread{}, err
 	}
@@ -83,34 +98,30 @@ func (p *postgresForumRepository) AddThread(thread models.Thread) (models.Thread
 			&threadObj.Title, &threadObj.Votes)
 	}
 	threadObj.Created = strfmt.DateTime(created.UTC()).String()
+
+	switch err = apperrors.FromPg(err); {
+	case errors.Is(err, apperrors.ErrConflict):
+		return threadObj, apperrors.Conflict("Thread with slug %s already exists", thread.Slug.String)
+	case errors.Is(err, apperrors.ErrNotFound):
+		return threadObj, apperrors.NotFound("Can't find thread author by nickname: %s", thread.Author)
+	}
 	return threadObj, err
 }
 
-func (p *postgresForumRepository) GetThreads(slug string, limit int, since string, desc bool) ([]models.Thread, error) {
-	var whereExpression string
-	var orderExpression string
-
+func (p *postgresForumRepository) GetThreads(ctx context.Context, slug string, limit int, since string, desc bool) ([]models.Thread, error) {
+	selectThreads := sqlbuilder.NewSelect(`SELECT `+threadColumns+` FROM thread`).Where(`LOWER(forum)=LOWER(?)`, slug)
 	if since != "" && desc {
-		whereExpression = fmt.Sprintf(`LOWER(forum)=LOWER('%s') AND created <= '%s'`, slug, since)
-	} else if since != "" && !desc {
-		whereExpression = fmt.Sprintf(`LOWER(forum)=LOWER('%s') AND created >= '%s'`, slug, since)
-	} else {
-		whereExpression = fmt.Sprintf(`LOWER(forum)=LOWER('%s')`, slug)
-	}
-	if desc {
-		orderExpression = `DESC`
-	} else {
-		orderExpression = `ASC`
+		selectThreads.Where(`created <= ?`, since)
+	} else if since != "" {
+		selectThreads.Where(`created >= ?`, since)
 	}
-
-	query := fmt.Sprintf("SELECT * FROM thread WHERE %s ORDER BY created %s LIMIT NULLIF(%d, 0)",
-		whereExpression, orderExpression, limit)
+	query, args := selectThreads.OrderBy(`created`, desc).Limit(limit).Build()
 
 	data := make([]models.Thread, 0, 0)
-	row, err := p.conn.Query(query)
+	row, err := p.conn.Query(query, args...)
 
 	if err != nil {
-		return nil, err
+		return nil, apperrors.FromPg(err)
 	}
 
 	defer func() {
@@ -134,20 +145,20 @@ func (p *postgresForumRepository) GetThreads(slug string, limit int, since strin
 		data = append(data, threadObj)
 	}
 
-	return data, err
+	return data, apperrors.FromPg(row.Err())
 }
 
-func (p *postgresForumRepository) CheckThreadExists(slug string) (bool, error) {
+func (p *postgresForumRepository) CheckThreadExists(ctx context.Context, slug string) (bool, error) {
 	query := `select exists(select 1 from thread where LOWER(forum)=LOWER($1))`
 
 	var exists bool
 
 	err := p.conn.QueryRow(query, slug).Scan(&exists)
-	return exists, err
+	return exists, apperrors.FromPg(err)
 }
 
-func (p *postgresForumRepository) GetThreadBySlug(slug string) (models.Thread, error) {
-	query := `SELECT * FROM thread WHERE LOWER(slug)=LOWER($1)`
+func (p *postgresForumRepository) GetThreadBySlug(ctx context.Context, slug string) (models.Thread, error) {
+	query := p.statement(threadBySlug)
 
 	var threadObj models.Thread
 	var created time.Time
@@ -156,11 +167,14 @@ func (p *postgresForumRepository) GetThreadBySlug(slug string) (models.Thread, e
 		&threadObj.Id, &threadObj.Message, &threadObj.Slug, &threadObj.Title, &threadObj.Votes)
 
 	threadObj.Created = strfmt.DateTime(created.UTC()).String()
-	return threadObj, err
+	if err == pgx.ErrNoRows {
+		return threadObj, apperrors.NotFound("Can't find thread by slug: %s", slug)
+	}
+	return threadObj, apperrors.FromPg(err)
 }
 
-func (p *postgresForumRepository) GetThreadByID(id int) (models.Thread, error) {
-	query := `SELECT * FROM thread WHERE id=$1`
+func (p *postgresForumRepository) GetThreadByID(ctx context.Context, id int) (models.Thread, error) {
+	query := p.statement(threadByID)
 
 	var threadObj models.Thread
 	var created time.Time
@@ -168,52 +182,158 @@ func (p *postgresForumRepository) GetThreadByID(id int) (models.Thread, error) {
 	err := p.conn.QueryRow(query, id).Scan(&threadObj.Author, &created, &threadObj.Forum,
 		&threadObj.Id, &threadObj.Message, &threadObj.Slug, &threadObj.Title, &threadObj.Votes)
 	threadObj.Created = strfmt.DateTime(created.UTC()).String()
+	if err == pgx.ErrNoRows {
+		return threadObj, apperrors.NotFound("Can't find thread by id: %d", id)
+	}
 
-	return threadObj, err
+	return threadObj, apperrors.FromPg(err)
 }
 
-func (p *postgresForumRepository) GetThreadIDBySlug(slug string) (int, error) {
-	query := `SELECT id FROM thread WHERE LOWER(slug)=LOWER($1)`
+func (p *postgresForumRepository) GetThreadIDBySlug(ctx context.Context, slug string) (int, error) {
+	query := p.statement(threadIDBySlug)
 
 	var id int
 	err := p.conn.QueryRow(query, slug).Scan(&id)
-	return id, err
+	if err == pgx.ErrNoRows {
+		return id, apperrors.NotFound("Can't find thread by slug: %s", slug)
+	}
+	return id, apperrors.FromPg(err)
+}
+
+func (p *postgresForumRepository) AddModerator(ctx context.Context, slug, nickname string) error {
+	tag, err := p.conn.Exec(`INSERT INTO forum_moderator(slug, nickname)
+SELECT forum.slug, users.nickname FROM forum, users WHERE forum.slug = $1 AND users.nickname = $2`, slug, nickname)
+	if errors.Is(apperrors.FromPg(err), apperrors.ErrConflict) {
+		return apperrors.Conflict("User %s already moderates forum %s", nickname, slug)
+	}
+	if err != nil {
+		return apperrors.FromPg(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.NotFound("Can't find forum %s or user %s", slug, nickname)
+	}
+	return nil
 }
 
-func (p *postgresForumRepository) GetThreadSlugByID(id int) (string, error) {
-	query := `SELECT slug FROM thread WHERE id=$1`
+func (p *postgresForumRepository) RemoveModerator(ctx context.Context, slug, nickname string) error {
+	tag, err := p.conn.Exec(`DELETE FROM forum_moderator WHERE slug = $1 AND nickname = $2`, slug, nickname)
+	if err != nil {
+		return apperrors.FromPg(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.NotFound("User %s doesn't moderate forum %s", nickname, slug)
+	}
+	return nil
+}
 
-	var slug string
-	err := p.conn.QueryRow(query, id).Scan(&slug)
-	return slug, err
+func (p *postgresForumRepository) IsModerator(ctx context.Context, slug, nickname string) (bool, error) {
+	var moderates bool
+	err := p.conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM forum WHERE slug = $1 AND "user" = $2)
+    OR EXISTS(SELECT 1 FROM forum_moderator WHERE slug = $1 AND nickname = $2)`, slug, nickname).Scan(&moderates)
+	return moderates, apperrors.FromPg(err)
 }
 
-func (p *postgresForumRepository) getForumSlug(threadID int) (string, error) {
-	query := `SELECT forum FROM thread WHERE id=$1`
+func (p *postgresForumRepository) GetThreadSlugByID(ctx context.Context, id int) (string, error) {
+	query := p.statement(threadSlugByID)
 
-	var slug string
-	err := p.conn.QueryRow(query, threadID).Scan(&slug)
-	return slug, err
+	var slug sql.NullString
+	err := p.conn.QueryRow(query, id).Scan(&slug)
+	if err == pgx.ErrNoRows {
+		return "", apperrors.NotFound("Can't find thread by id: %d", id)
+	}
+	return slug.String, apperrors.FromPg(err)
 }
 
-func (p *postgresForumRepository) AddPosts(posts []models.Post, threadID int) ([]models.Post, error) {
-	query := `INSERT INTO post(
-                 author,
-                 created,
-                 message,
-                 parent,
-				 thread,
-				 forum) VALUES `
+// AddPosts creates all posts of a batch in one transaction. Authors and
+// parents are checked before anything is inserted, so a failure names the
+// first offending post by its index in the batch.
+func (p *postgresForumRepository) AddPosts(ctx context.Context, posts []models.Post, threadID int) ([]models.Post, error) {
 	data := make([]models.Post, 0, 0)
 	if len(posts) == 0 {
 		return data, nil
 	}
 
-	slug, err := p.getForumSlug(threadID)
+	tx, err := p.conn.Begin()
+	if err != nil {
+		return data, err
+	}
+	defer tx.Rollback()
+
+	var forumSlug string
+	err = tx.QueryRow(p.statement(threadForum), threadID).Scan(&forumSlug)
+	if err == pgx.ErrNoRows {
+		return data, apperrors.NotFound("Can't find thread by id: %d", threadID)
+	}
 	if err != nil {
+		return data, apperrors.FromPg(err)
+	}
+
+	if err = p.validatePosts(tx, posts, threadID); err != nil {
 		return data, err
 	}
 
+	if len(posts) >= p.copyThreshold {
+		data, err = p.copyPosts(tx, posts, threadID, forumSlug)
+	} else {
+		data, err = p.insertPosts(tx, posts, threadID, forumSlug)
+	}
+	if err != nil {
+		return data, err
+	}
+
+	authors := make([]string, 0, len(data))
+	for _, post := range data {
+		authors = append(authors, post.Author)
+	}
+	if _, err = tx.Exec(p.statement(addForumPosts), forumSlug, len(data)); err != nil {
+		return data, apperrors.FromPg(err)
+	}
+	if _, err = tx.Exec(p.statement(insertUsersForum), authors, forumSlug); err != nil {
+		return data, apperrors.FromPg(err)
+	}
+
+	return data, apperrors.FromPg(tx.Commit())
+}
+
+// validatePosts checks in a single query that every author exists and every
+// parent is a post of the same thread.
+func (p *postgresForumRepository) validatePosts(tx *pgx.Tx, posts []models.Post, threadID int) error {
+	authors := make([]string, 0, len(posts))
+	parents := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		authors = append(authors, post.Author)
+		parents = append(parents, post.Parent.Int64)
+	}
+
+	var badAuthor, badParent sql.NullInt64
+	err := tx.QueryRow(p.statement(validatePosts), authors, parents, threadID).Scan(&badAuthor, &badParent)
+	if err != nil {
+		return apperrors.FromPg(err)
+	}
+
+	if badAuthor.Valid {
+		i := badAuthor.Int64 - 1
+		return apperrors.NotFound("Can't find author %s of post %d", posts[i].Author, i)
+	}
+	if badParent.Valid {
+		i := badParent.Int64 - 1
+		return apperrors.New(apperrors.ErrParentInOtherThread,
+			"Parent post %d of post %d is not in thread %d", posts[i].Parent.Int64, i, threadID)
+	}
+	return nil
+}
+
+func (p *postgresForumRepository) insertPosts(tx *pgx.Tx, posts []models.Post, threadID int,
+	forumSlug string) ([]models.Post, error) {
+	query := `INSERT INTO post(
+                 author,
+                 created,
+                 message,
+                 parent,
+				 thread,
+				 forum) VALUES `
+	data := make([]models.Post, 0, len(posts))
+
 	timeCreated := time.Now()
 	var valuesNames []string
 	var values []interface{}
@@ -223,81 +343,117 @@ func (p *postgresForumRepository) AddPosts(posts []models.Post, threadID int) ([
 			"($%d, $%d, $%d, nullif($%d, 0), $%d, $%d)",
 			i, i+1, i+2, i+3, i+4, i+5))
 		i += 6
-		values = append(values, element.Author, timeCreated, element.Message, element.Parent, threadID, slug)
+		values = append(values, element.Author, timeCreated, element.Message, element.Parent, threadID, forumSlug)
 	}
 
 	query += strings.Join(valuesNames[:], ",")
-	query += " RETURNING *"
-	row, err := p.conn.Query(query, values...)
+	query += " RETURNING " + postColumns
+	row, err := tx.Query(query, values...)
+	if err != nil {
+		return data, postError(err)
+	}
 
+	return scanPosts(row)
+}
+
+// copyPosts loads a large batch into a temporary table with COPY and moves it
+// into post with a single INSERT, which keeps the number of query parameters
+// independent of the batch size. The update_path trigger still computes paths.
+func (p *postgresForumRepository) copyPosts(tx *pgx.Tx, posts []models.Post, threadID int,
+	forumSlug string) ([]models.Post, error) {
+	_, err := tx.Exec(`CREATE TEMP TABLE post_import (
+    idx     INT,
+    author  TEXT,
+    message TEXT,
+    parent  BIGINT
+) ON COMMIT DROP`)
 	if err != nil {
-		return data, err
+		return nil, apperrors.FromPg(err)
 	}
-	defer func() {
-		if row != nil {
-			row.Close()
+
+	rows := make([][]interface{}, 0, len(posts))
+	for i, post := range posts {
+		var parent interface{}
+		if post.Parent.Valid && post.Parent.Int64 != 0 {
+			parent = post.Parent.Int64
 		}
-	}()
+		rows = append(rows, []interface{}{i, post.Author, post.Message, parent})
+	}
 
-	for row.Next() {
+	_, err = tx.CopyFrom(pgx.Identifier{"post_import"}, []string{"idx", "author", "message", "parent"},
+		pgx.CopyFromRows(rows))
+	if err != nil {
+		return nil, apperrors.FromPg(err)
+	}
 
+	row, err := tx.Query(`INSERT INTO post(author, created, message, parent, thread, forum)
+	SELECT author::citext, $1, message, parent, $2, $3 FROM post_import ORDER BY idx
+	RETURNING `+postColumns, time.Now(), threadID, forumSlug)
+	if err != nil {
+		return nil, postError(err)
+	}
+
+	data, err := scanPosts(row)
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Id < data[j].Id
+	})
+	return data, err
+}
+
+func scanPosts(row *pgx.Rows) ([]models.Post, error) {
+	defer row.Close()
+
+	data := make([]models.Post, 0, 0)
+	for row.Next() {
 		var post models.Post
 		var created time.Time
 
-		err = row.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited,
+		err := row.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.IsDeleted,
 			&post.Message, &post.Parent, &post.Thread, &post.Path)
 
 		if err != nil {
-			return data, err
+			return data, postError(err)
 		}
 		post.Created = strfmt.DateTime(created.UTC()).String()
 		data = append(data, post)
-
 	}
 
-	return data, err
+	return data, postError(row.Err())
 }
 
-func (p *postgresForumRepository) AddVote(vote models.Vote) error {
-	query := `INSERT INTO vote(
-				nickname,  
-				voice,     
-				idThread)
-				VALUES ($1, $2, NULLIF($3, 0))`
+func (p *postgresForumRepository) AddVote(ctx context.Context, vote models.Vote) error {
+	query := p.statement(insertVote)
 
 	_, err := p.conn.Exec(query, vote.Nickname, vote.Voice, vote.IdThread)
-	return err
+	if errors.Is(apperrors.FromPg(err), apperrors.ErrNotFound) {
+		return apperrors.NotFound("Can't find user by nickname: %s", vote.Nickname)
+	}
+	return apperrors.FromPg(err)
 }
 
-func (p *postgresForumRepository) UpdateVote(vote models.Vote) error {
-	query := `UPDATE vote SET voice=$1 WHERE LOWER(nickname) = LOWER($2) AND idThread = $3`
+func (p *postgresForumRepository) UpdateVote(ctx context.Context, vote models.Vote) error {
+	query := p.statement(updateVote)
 	_, err := p.conn.Exec(query, vote.Voice, vote.Nickname, vote.IdThread)
-	return err
+	return apperrors.FromPg(err)
 }
 
-func (p *postgresForumRepository) getPostsFlat(threadID, limit, since int,
+func (p *postgresForumRepository) getPostsFlat(ctx context.Context, threadID, limit, since int,
 	desc bool) ([]models.Post, error) {
 
-	query := `SELECT * FROM post WHERE thread=$1 `
-
-	if desc {
-		if since > 0 {
-			query += fmt.Sprintf("AND id < %d ", since)
-		}
-		query += `ORDER BY id DESC `
-	} else {
-		if since > 0 {
-			query += fmt.Sprintf("AND id > %d ", since)
-		}
-		query += `ORDER BY id `
+	selectPosts := sqlbuilder.NewSelect(`SELECT `+postColumns+` FROM post`).Where(`thread = ?`, threadID)
+	if since > 0 && desc {
+		selectPosts.Where(`id < ?`, since)
+	} else if since > 0 {
+		selectPosts.Where(`id > ?`, since)
 	}
-	query += `LIMIT NULLIF($2, 0)`
+	query, args := selectPosts.OrderBy(`id`, desc).Limit(limit).Build()
+
 	var posts []models.Post
 
-	row, err := p.conn.Query(query, threadID, limit)
+	row, err := p.conn.Query(query, args...)
 
 	if err != nil {
-		return posts, err
+		return posts, apperrors.FromPg(err)
 	}
 	defer func() {
 		if row != nil {
@@ -309,43 +465,34 @@ func (p *postgresForumRepository) getPostsFlat(threadID, limit, since int,
 		var post models.Post
 		var created time.Time
 
-		err = row.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.Message,
-			&post.Parent, &post.Thread, &post.Path)
+		err = row.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.IsDeleted,
+			&post.Message, &post.Parent, &post.Thread, &post.Path)
 
 		if err != nil {
-			return posts, err
+			return posts, apperrors.FromPg(err)
 		}
 		post.Created = strfmt.DateTime(created.UTC()).String()
 		posts = append(posts, post)
 
 	}
-	return posts, err
+	return posts, apperrors.FromPg(row.Err())
 }
 
-func (p *postgresForumRepository) getPostsTree(threadID, limit, since int,
+func (p *postgresForumRepository) getPostsTree(ctx context.Context, threadID, limit, since int,
 	desc bool) ([]models.Post, error) {
-	var query string
-	sinceQuery := ""
-	if since != 0 {
-		if desc {
-			sinceQuery = `AND PATH < `
-		} else {
-			sinceQuery = `AND PATH > `
-		}
-		sinceQuery += fmt.Sprintf(`(SELECT path FROM post WHERE id = %d)`, since)
-	}
-	if desc {
-		query = fmt.Sprintf(
-			`SELECT * FROM post WHERE thread=$1 %s ORDER BY path DESC, id DESC LIMIT NULLIF($2, 0);`, sinceQuery)
-	} else {
-		query = fmt.Sprintf(
-			`SELECT * FROM post WHERE thread=$1 %s ORDER BY path, id LIMIT NULLIF($2, 0);`, sinceQuery)
+	selectPosts := sqlbuilder.NewSelect(`SELECT `+postColumns+` FROM post`).Where(`thread = ?`, threadID)
+	if since != 0 && desc {
+		selectPosts.Where(`path < (SELECT path FROM post WHERE id = ?)`, since)
+	} else if since != 0 {
+		selectPosts.Where(`path > (SELECT path FROM post WHERE id = ?)`, since)
 	}
+	query, args := selectPosts.OrderBy(`path`, desc).OrderBy(`id`, desc).Limit(limit).Build()
+
 	var posts []models.Post
-	row, err := p.conn.Query(query, threadID, limit)
+	row, err := p.conn.Query(query, args...)
 
 	if err != nil {
-		return posts, err
+		return posts, apperrors.FromPg(err)
 	}
 	defer func() {
 		if row != nil {
@@ -357,55 +504,44 @@ func (p *postgresForumRepository) getPostsTree(threadID, limit, since int,
 		var post models.Post
 		var created time.Time
 
-		err = row.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.Message,
-			&post.Parent, &post.Thread, &post.Path)
+		err = row.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.IsDeleted,
+			&post.Message, &post.Parent, &post.Thread, &post.Path)
 
 		if err != nil {
-			return posts, err
+			return posts, apperrors.FromPg(err)
 		}
 		post.Created = strfmt.DateTime(created.UTC()).String()
 		posts = append(posts, post)
 
 	}
-	return posts, err
+	return posts, apperrors.FromPg(row.Err())
 }
 
-func (p *postgresForumRepository) getPostsParentTree(threadID, limit, since int,
+func (p *postgresForumRepository) getPostsParentTree(ctx context.Context, threadID, limit, since int,
 	desc bool) ([]models.Post, error) {
-	var query string
-	sinceQuery := ""
-	if since != 0 {
-		if desc {
-			sinceQuery = `AND PATH[1] < `
-		} else {
-			sinceQuery = `AND PATH[1] > `
-		}
-		sinceQuery += fmt.Sprintf(`(SELECT path[1] FROM post WHERE id = %d)`, since)
+	selectParents := sqlbuilder.NewSelect(`SELECT id FROM post`).
+		Where(`thread = ?`, threadID).
+		Where(`parent IS NULL`)
+	if since != 0 && desc {
+		selectParents.Where(`path[1] < (SELECT path[1] FROM post WHERE id = ?)`, since)
+	} else if since != 0 {
+		selectParents.Where(`path[1] > (SELECT path[1] FROM post WHERE id = ?)`, since)
 	}
+	parents, parentArgs := selectParents.OrderBy(`id`, desc).Limit(limit).Subquery()
 
-	parentsQuery := fmt.Sprintf(
-		`SELECT id FROM post WHERE thread = $1 AND parent IS NULL %s`, sinceQuery)
-
+	selectPosts := sqlbuilder.NewSelect(`SELECT `+postColumns+` FROM post`).Where(`path[1] IN (`+parents+`)`, parentArgs...)
 	if desc {
-		parentsQuery += `ORDER BY id DESC`
-		if limit > 0 {
-			parentsQuery += fmt.Sprintf(` LIMIT %d`, limit)
-		}
-		query = fmt.Sprintf(
-			`SELECT * FROM post WHERE path[1] IN (%s) ORDER BY path[1] DESC, path, id;`, parentsQuery)
+		selectPosts.OrderBy(`path[1]`, true).OrderBy(`path`, false).OrderBy(`id`, false)
 	} else {
-		parentsQuery += `ORDER BY id`
-		if limit > 0 {
-			parentsQuery += fmt.Sprintf(` LIMIT %d`, limit)
-		}
-		query = fmt.Sprintf(
-			`SELECT * FROM post WHERE path[1] IN (%s) ORDER BY path,id;`, parentsQuery)
+		selectPosts.OrderBy(`path`, false).OrderBy(`id`, false)
 	}
+	query, args := selectPosts.Build()
+
 	var posts []models.Post
-	row, err := p.conn.Query(query, threadID)
+	row, err := p.conn.Query(query, args...)
 
 	if err != nil {
-		return posts, err
+		return posts, apperrors.FromPg(err)
 	}
 
 	defer func() {
@@ -418,25 +554,25 @@ func (p *postgresForumRepository) getPostsParentTree(threadID, limit, since int,
 		var post models.Post
 		var created time.Time
 
-		err = row.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.Message,
-			&post.Parent, &post.Thread, &post.Path)
+		err = row.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.IsDeleted,
+			&post.Message, &post.Parent, &post.Thread, &post.Path)
 
 		if err != nil {
-			return posts, err
+			return posts, apperrors.FromPg(err)
 		}
 		post.Created = strfmt.DateTime(created.UTC()).String()
 		posts = append(posts, post)
 
 	}
-	return posts, err
+	return posts, apperrors.FromPg(row.Err())
 }
 
-func (p *postgresForumRepository) GetPosts(postSlugOrId models.Thread, limit, since int,
+func (p *postgresForumRepository) GetPosts(ctx context.Context, postSlugOrId models.Thread, limit, since int,
 	sort string, desc bool) ([]models.Post, error) {
 	var err error
 	threadId := 0
 	if postSlugOrId.Id <= 0 {
-		threadId, err = p.GetThreadIDBySlug(postSlugOrId.Slug.String)
+		threadId, err = p.GetThreadIDBySlug(ctx, postSlugOrId.Slug.String)
 		if err != nil {
 			return nil, err
 		}
@@ -446,23 +582,29 @@ func (p *postgresForumRepository) GetPosts(postSlugOrId models.Thread, limit, si
 
 	switch sort {
 	case "flat":
-		return p.getPostsFlat(threadId, limit, since, desc)
+		return p.getPostsFlat(ctx, threadId, limit, since, desc)
 	case "tree":
-		return p.getPostsTree(threadId, limit, since, desc)
+		return p.getPostsTree(ctx, threadId, limit, since, desc)
 	case "parent_tree":
-		return p.getPostsParentTree(threadId, limit, since, desc)
+		return p.getPostsParentTree(ctx, threadId, limit, since, desc)
 	default:
-		return nil, errors.New("THERE IS NO SORT WITH THIS NAME")
+		return nil, apperrors.Validation("Unknown sort type: %s", sort)
 	}
 }
 
-func (p *postgresForumRepository) GetPost(id int, related []string) (map[string]interface{}, error) {
-	query := `SELECT * FROM post WHERE id = $1;`
+func (p *postgresForumRepository) GetPost(ctx context.Context, id int, related []string) (map[string]interface{}, error) {
+	query := p.statement(postByID)
 	var post models.Post
 	var created time.Time
 
 	err := p.conn.QueryRow(query, id).Scan(&post.Author, &created, &post.Forum,
-		&post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread, &post.Path)
+		&post.Id, &post.IsEdited, &post.IsDeleted, &post.Message, &post.Parent, &post.Thread, &post.Path)
+	if err == pgx.ErrNoRows {
+		return nil, apperrors.NotFound("Can't find post with id: %d", id)
+	}
+	if err != nil {
+		return nil, apperrors.FromPg(err)
+	}
 	post.Created = strfmt.DateTime(created.UTC()).String()
 
 	returnMap := map[string]interface{}{
@@ -472,19 +614,19 @@ func (p *postgresForumRepository) GetPost(id int, related []string) (map[string]
 	for _, relatedObj := range related {
 		switch relatedObj {
 		case "user":
-			author, err := p.userRepo.GetByNick(post.Author)
+			author, err := p.userRepo.GetByNick(ctx, post.Author)
 			if err != nil {
 				return returnMap, err
 			}
 			returnMap["author"] = author
 		case "thread":
-			thread, err := p.GetThreadByID(int(post.Thread))
+			thread, err := p.GetThreadByID(ctx, int(post.Thread))
 			if err != nil {
 				return returnMap, err
 			}
 			returnMap["thread"] = thread
 		case "forum":
-			forumObj, err := p.GetBySlug(post.Forum)
+			forumObj, err := p.GetBySlug(ctx, post.Forum)
 			if err != nil {
 				return returnMap, err
 			}
@@ -492,68 +634,208 @@ func (p *postgresForumRepository) GetPost(id int, related []string) (map[string]
 		}
 	}
 
-	return returnMap, err
+	return returnMap, nil
 }
 
-func (p *postgresForumRepository) UpdatePost(newPost models.Post) (models.Post, error) {
-	query := `UPDATE post SET message = $1, isEdited = true WHERE id = $2 RETURNING *;`
+func (p *postgresForumRepository) UpdatePost(ctx context.Context, newPost models.Post) (models.Post, error) {
+	query := p.statement(updatePostMessage)
 
-	oldPost, err := p.GetPost(int(newPost.Id), []string{})
+	oldPost, err := p.GetPost(ctx, int(newPost.Id), []string{})
 	if err != nil {
 		return models.Post{}, err
 	}
+	if oldPost["post"].(models.Post).IsDeleted {
+		return models.Post{}, apperrors.Conflict("Post %d is deleted", newPost.Id)
+	}
 	if oldPost["post"].(models.Post).Message == newPost.Message {
 		return oldPost["post"].(models.Post), nil
 	}
 
 	if newPost.Message == "" {
-		query := `SELECT * FROM post WHERE id = $1`
+		query := p.statement(postByID)
 		var post models.Post
 		var created time.Time
 
 		err := p.conn.QueryRow(query, newPost.Id).Scan(&post.Author, &created,
-			&post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread, &post.Path)
+			&post.Forum, &post.Id, &post.IsEdited, &post.IsDeleted, &post.Message, &post.Parent, &post.Thread,
+			&post.Path)
 
 		post.Created = strfmt.DateTime(created.UTC()).String()
-		return post, err
+		return post, apperrors.FromPg(err)
 	}
 
 	var post models.Post
 	var created time.Time
 
 	err = p.conn.QueryRow(query, newPost.Message, newPost.Id).Scan(&post.Author, &created,
-		&post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread, &post.Path)
+		&post.Forum, &post.Id, &post.IsEdited, &post.IsDeleted, &post.Message, &post.Parent, &post.Thread,
+		&post.Path)
 	post.Created = strfmt.DateTime(created.UTC()).String()
 
-	return post, err
+	return post, apperrors.FromPg(err)
+}
+
+const (
+	deletePostQuery = `WITH deleted AS (UPDATE post SET isDeleted = true WHERE id = $1 AND NOT isDeleted RETURNING forum)
+UPDATE forum SET posts = forum.posts - 1 FROM deleted WHERE forum.slug = deleted.forum`
+
+	// A tombstone goes once no post below it, itself included, is alive. Posts
+	// below share the root and thread of the tombstone, which keeps the lookup
+	// on post_first_parent_thread_index instead of scanning every post.
+	purgeDeletedPostsQuery = `DELETE FROM post WHERE isDeleted
+  AND NOT EXISTS(SELECT 1 FROM post below WHERE below.path[1] = post.path[1] AND below.thread = post.thread
+    AND below.path && ARRAY [post.id] AND NOT below.isDeleted)`
+)
+
+func (p *postgresForumRepository) DeletePost(ctx context.Context, id int) (models.Post, error) {
+	tag, err := p.conn.Exec(deletePostQuery, id)
+	if err != nil {
+		return models.Post{}, apperrors.FromPg(err)
+	}
+
+	post, err := p.GetPost(ctx, id, nil)
+	if err != nil {
+		return models.Post{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.Post{}, apperrors.Conflict("Post %d is already deleted", id)
+	}
+	return post["post"].(models.Post), nil
+}
+
+func (p *postgresForumRepository) PurgeDeletedPosts(ctx context.Context) (int64, error) {
+	tag, err := p.conn.Exec(purgeDeletedPostsQuery)
+	if err != nil {
+		return 0, apperrors.FromPg(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// searchFilters adds the conditions and ordering shared by thread and post search.
+func searchFilters(selectResults *sqlbuilder.Select, query models.SearchQuery) *sqlbuilder.Select {
+	if query.Forum != "" {
+		selectResults.Where(`forum = ?`, query.Forum)
+	}
+	if query.Author != "" {
+		selectResults.Where(`author = ?`, query.Author)
+	}
+	return selectResults.OrderBy(`rank`, true).OrderBy(`created`, query.Desc).OrderBy(`id`, query.Desc).
+		Limit(query.Limit)
+}
+
+func (p *postgresForumRepository) searchThreads(query models.SearchQuery) ([]models.SearchResult, error) {
+	selectThreads := sqlbuilder.NewSelect(`SELECT `+threadColumns+`, ts_rank(search, query) AS rank,
+	ts_headline('simple', translate(title || ' ' || message, ?, ''), query, ?)
+	FROM thread, websearch_to_tsquery('simple', ?) AS query`,
+		matchMarks, headlineOptions, query.Text).Where(`search @@ query`)
+	sqlQuery, args := searchFilters(selectThreads, query).Build()
+
+	row, err := p.conn.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, apperrors.FromPg(err)
+	}
+	defer row.Close()
+
+	var results []models.SearchResult
+	for row.Next() {
+		threadObj := &models.Thread{}
+		result := models.SearchResult{Type: models.SearchThread, Thread: threadObj}
+		var created time.Time
+
+		err = row.Scan(&threadObj.Author, &created, &threadObj.Forum, &threadObj.Id, &threadObj.Message,
+			&threadObj.Slug, &threadObj.Title, &threadObj.Votes, &result.Rank, &result.Snippet)
+		if err != nil {
+			return nil, apperrors.FromPg(err)
+		}
+		threadObj.Created = strfmt.DateTime(created.UTC()).String()
+		result.Snippet = snippetHTML(result.Snippet)
+		results = append(results, result)
+	}
+	return results, apperrors.FromPg(row.Err())
 }
 
-func (p *postgresForumRepository) UpdateThread(newThread models.Thread) (models.Thread, error) {
+func (p *postgresForumRepository) searchPosts(query models.SearchQuery) ([]models.SearchResult, error) {
+	selectPosts := sqlbuilder.NewSelect(`SELECT `+postColumns+`, ts_rank(search, query) AS rank,
+	ts_headline('simple', translate(message, ?, ''), query, ?)
+	FROM post, websearch_to_tsquery('simple', ?) AS query`,
+		matchMarks, headlineOptions, query.Text).Where(`search @@ query`).Where(`NOT isDeleted`)
+	sqlQuery, args := searchFilters(selectPosts, query).Build()
+
+	row, err := p.conn.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, apperrors.FromPg(err)
+	}
+	defer row.Close()
+
+	var results []models.SearchResult
+	for row.Next() {
+		post := &models.Post{}
+		result := models.SearchResult{Type: models.SearchPost, Post: post}
+		var created time.Time
+
+		err = row.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.IsDeleted,
+			&post.Message, &post.Parent, &post.Thread, &post.Path, &result.Rank, &result.Snippet)
+		if err != nil {
+			return nil, apperrors.FromPg(err)
+		}
+		post.Created = strfmt.DateTime(created.UTC()).String()
+		result.Snippet = snippetHTML(result.Snippet)
+		results = append(results, result)
+	}
+	return results, apperrors.FromPg(row.Err())
+}
+
+func (p *postgresForumRepository) Search(ctx context.Context, query models.SearchQuery) ([]models.SearchResult, error) {
+	results := make([]models.SearchResult, 0)
+	if query.Type != models.SearchPost {
+		threads, err := p.searchThreads(query)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, threads...)
+	}
+	if query.Type != models.SearchThread {
+		posts, err := p.searchPosts(query)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, posts...)
+	}
+	return mergeResults(results, query), nil
+}
+
+func (p *postgresForumRepository) UpdateThread(ctx context.Context, newThread models.Thread) (models.Thread, error) {
 	query := `UPDATE thread SET message=COALESCE(NULLIF($1, ''), message), title=COALESCE(NULLIF($2, ''), title) WHERE `
 
 	if newThread.Id > 0 {
-		query += `id = $3 RETURNING *`
+		query += `id = $3 RETURNING ` + threadColumns
 		var threadObj models.Thread
 		var created time.Time
 		err := p.conn.QueryRow(query, newThread.Message, newThread.Title, newThread.Id).Scan(
 			&threadObj.Author, &created, &threadObj.Forum, &threadObj.Id, &threadObj.Message, &threadObj.Slug,
 			&threadObj.Title, &threadObj.Votes)
 		threadObj.Created = strfmt.DateTime(created.UTC()).String()
-		return threadObj, err
+		if err == pgx.ErrNoRows {
+			return threadObj, apperrors.NotFound("Can't find thread by id: %d", newThread.Id)
+		}
+		return threadObj, apperrors.FromPg(err)
 	} else {
-		query += `LOWER(slug) = LOWER($3) RETURNING *`
+		query += `LOWER(slug) = LOWER($3) RETURNING ` + threadColumns
 		var threadObj models.Thread
 		var created time.Time
 		err := p.conn.QueryRow(query, newThread.Message, newThread.Title, newThread.Slug).Scan(
 			&threadObj.Author, &created, &threadObj.Forum, &threadObj.Id, &threadObj.Message, &threadObj.Slug,
 			&threadObj.Title, &threadObj.Votes)
 		threadObj.Created = strfmt.DateTime(created.UTC()).String()
-		return threadObj, err
+		if err == pgx.ErrNoRows {
+			return threadObj, apperrors.NotFound("Can't find thread by slug: %s", newThread.Slug.String)
+		}
+		return threadObj, apperrors.FromPg(err)
 	}
 }
 
-func (p *postgresForumRepository) GetServiceStatus() (map[string]int, error) {
-	query := `SELECT * FROM (SELECT COUNT(*) FROM forum) as fC, (SELECT COUNT(*) FROM post) as pC,
+func (p *postgresForumRepository) GetServiceStatus(ctx context.Context) (map[string]int, error) {
+	query := `SELECT * FROM (SELECT COUNT(*) FROM forum) as fC, (SELECT COUNT(*) FROM post WHERE NOT isDeleted) as pC,
               (SELECT COUNT(*) FROM thread) as tC, (SELECT COUNT(*) FROM users) as uC;`
 
 	a, err := p.conn.Query(query)
@@ -577,9 +859,20 @@ func (p *postgresForumRepository) GetServiceStatus() (map[string]int, error) {
 	return nil, errors.New("no info available")
 }
 
-func (p *postgresForumRepository) ClearDatabase() error {
-	query := `TRUNCATE users, forum, thread, post, vote, users_forum;`
+func (p *postgresForumRepository) ClearDatabase(ctx context.Context) error {
+	query := `TRUNCATE users, forum, thread, post, vote, users_forum, user_alias, session, api_token, forum_moderator RESTART IDENTITY;`
 
 	_, err := p.conn.Exec(query)
 	return err
 }
+
+// postError names the reference that made a post insert fail.
+func postError(err error) error {
+	switch err = apperrors.FromPg(err); {
+	case errors.Is(err, apperrors.ErrNotFound):
+		return apperrors.NotFound("Can't find post author")
+	case errors.Is(err, apperrors.ErrParentInOtherThread):
+		return apperrors.New(apperrors.ErrParentInOtherThread, "Parent post was created in another thread")
+	}
+	return err
+}
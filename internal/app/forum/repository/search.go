@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/forum/models"
+	"html"
+	"sort"
+	"strings"
+)
+
+// matchStart and matchStop mark matched words while a snippet is still plain
+// text. They are control characters, so escaping leaves them alone, and
+// marks already in the source text are dropped first.
+const (
+	matchStart = "\x02"
+	matchStop  = "\x03"
+	matchMarks = matchStart + matchStop
+)
+
+// headlineOptions configures the Postgres snippets: matched words are marked
+// for snippetHTML.
+const headlineOptions = `StartSel="` + matchStart + `", StopSel="` + matchStop +
+	`", MaxFragments=2, MaxWords=30, MinWords=10`
+
+var (
+	unmarker   = strings.NewReplacer(matchStart, "", matchStop, "")
+	markToHTML = strings.NewReplacer(matchStart, "<b>", matchStop, "</b>")
+)
+
+// snippetHTML escapes a marked snippet for HTML and wraps the matched words
+// in <b> tags, so markup in posts comes out as text.
+func snippetHTML(snippet string) string {
+	return markToHTML.Replace(html.EscapeString(snippet))
+}
+
+func resultKey(result models.SearchResult) (string, int64) {
+	if result.Thread != nil {
+		return result.Thread.Created, int64(result.Thread.Id)
+	}
+	return result.Post.Created, result.Post.Id
+}
+
+// mergeResults orders thread and post results together, best rank first and
+// equal ranks by creation time and id, and applies the limit.
+func mergeResults(results []models.SearchResult, query models.SearchQuery) []models.SearchResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		createdI, idI := resultKey(results[i])
+		createdJ, idJ := resultKey(results[j])
+		if createdI != createdJ {
+			return (createdI < createdJ) != query.Desc
+		}
+		return (idI < idJ) != query.Desc
+	})
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results
+}
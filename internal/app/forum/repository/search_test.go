@@ -0,0 +1,38 @@
+package repository
+
+import "testing"
+
+func TestSnippetEscapesMarkup(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{"plain text", "Hello world", []string{"hello"}, "<b>Hello</b> world"},
+		{"tags in text", `<script>alert("x")</script> hello`, []string{"hello"},
+			"&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; <b>hello</b>"},
+		{"match inside a tag", "<b>hello</b>", []string{"hello"}, "&lt;b&gt;<b>hello</b>&lt;/b&gt;"},
+		{"marks in text", "\x02hello\x03 \x03world", []string{"world"}, "hello <b>world</b>"},
+	}
+	for _, test := range tests {
+		_, got, ok := matchText(test.text, test.terms)
+		if !ok {
+			t.Errorf("%s: matchText(%q) found no match", test.name, test.text)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: snippet of %q = %q, want %q", test.name, test.text, got, test.want)
+		}
+	}
+}
+
+// ts_headline marks matches the same way, so Postgres snippets go through the
+// same escaping.
+func TestSnippetHTML(t *testing.T) {
+	got := snippetHTML("a <i>\x02match\x03</i> & more")
+	want := "a &lt;i&gt;<b>match</b>&lt;/i&gt; &amp; more"
+	if got != want {
+		t.Errorf("snippetHTML = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+package repository
+
+import "github.com/jackc/pgx"
+
+const (
+	insertForum       = "insert_forum"
+	forumBySlug       = "forum_by_slug"
+	insertThread      = "insert_thread"
+	threadBySlug      = "thread_by_slug"
+	threadByID        = "thread_by_id"
+	threadIDBySlug    = "thread_id_by_slug"
+	threadSlugByID    = "thread_slug_by_id"
+	threadForum       = "thread_forum"
+	validatePosts     = "validate_posts"
+	addForumPosts     = "add_forum_posts"
+	insertUsersForum  = "insert_users_forum"
+	insertVote        = "insert_vote"
+	updateVote        = "update_vote"
+	postByID          = "post_by_id"
+	updatePostMessage = "update_post_message"
+)
+
+// threadColumns and postColumns are the columns, in scan order, that thread
+// and post queries return, so that added columns do not break the scans.
+// Messages of deleted posts are never read back.
+const (
+	threadColumns = `author, created, forum, id, message, slug, title, votes`
+	postColumns   = `author, created, forum, id, isEdited, isDeleted,
+	CASE WHEN isDeleted THEN '' ELSE message END, parent, thread, path`
+)
+
+var statements = map[string]string{
+	insertForum: `INSERT INTO forum(
+    "user",
+    slug,
+    title)
+	VALUES ($1, $2, $3) RETURNING *`,
+	forumBySlug: `SELECT * FROM forum WHERE LOWER(slug)=LOWER($1)`,
+	insertThread: `INSERT INTO thread(
+    slug,
+    author,
+    created,
+    message,
+    title,
+	forum)
+	VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6) RETURNING ` + threadColumns,
+	threadBySlug:   `SELECT ` + threadColumns + ` FROM thread WHERE LOWER(slug)=LOWER($1)`,
+	threadByID:     `SELECT ` + threadColumns + ` FROM thread WHERE id=$1`,
+	threadIDBySlug: `SELECT id FROM thread WHERE LOWER(slug)=LOWER($1)`,
+	threadSlugByID: `SELECT slug FROM thread WHERE id=$1`,
+	threadForum:    `SELECT forum FROM thread WHERE id=$1`,
+	validatePosts: `SELECT
+    (SELECT min(a.idx) FROM unnest($1::text[]) WITH ORDINALITY AS a(nickname, idx)
+     WHERE NOT EXISTS(SELECT 1 FROM users WHERE users.nickname = a.nickname::citext)),
+    (SELECT min(p.idx) FROM unnest($2::bigint[]) WITH ORDINALITY AS p(parent, idx)
+     WHERE p.parent <> 0 AND NOT EXISTS(SELECT 1 FROM post WHERE post.id = p.parent AND post.thread = $3))`,
+	addForumPosts: `UPDATE forum SET posts = posts + $2 WHERE slug = $1`,
+	insertUsersForum: `INSERT INTO users_forum (nickname, slug)
+	SELECT DISTINCT unnest($1::text[])::citext, $2 ON CONFLICT DO NOTHING`,
+	insertVote: `INSERT INTO vote(
+    nickname,
+    voice,
+    idThread)
+	VALUES ($1, $2, NULLIF($3, 0))`,
+	updateVote:        `UPDATE vote SET voice=$1 WHERE LOWER(nickname) = LOWER($2) AND idThread = $3`,
+	postByID:          `SELECT ` + postColumns + ` FROM post WHERE id = $1`,
+	updatePostMessage: `UPDATE post SET message = $1, isEdited = true WHERE id = $2 RETURNING ` + postColumns,
+}
+
+// PrepareStatements registers the hot queries of the forum repository on a
+// new pool connection. It is meant to be called from ConnPoolConfig.AfterConnect.
+func PrepareStatements(conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(name, sql); err != nil {
+			return err
+		}
+	}
+	return nil
+}
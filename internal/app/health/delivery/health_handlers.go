@@ -0,0 +1,92 @@
+package delivery
+
+import (
+	"DbProjectForum/internal/pkg/requestid"
+	"DbProjectForum/internal/pkg/responses"
+	"context"
+	"github.com/fasthttp/router"
+	"github.com/jackc/pgx"
+	"github.com/valyala/fasthttp"
+	"time"
+)
+
+const pingTimeout = 2 * time.Second
+
+type poolStatus struct {
+	Max        int     `json:"max"`
+	Current    int     `json:"current"`
+	Available  int     `json:"available"`
+	InUse      int     `json:"in_use"`
+	Saturation float64 `json:"saturation"`
+}
+
+type readiness struct {
+	Status   string     `json:"status"`
+	Postgres string     `json:"postgres"`
+	Pool     poolStatus `json:"pool"`
+}
+
+type healthHandler struct {
+	conn *pgx.ConnPool
+}
+
+// NewHealthHandler registers the probes. conn is nil when the repositories do
+// not use Postgres, in which case readiness does not depend on it.
+func NewHealthHandler(r *router.Router, conn *pgx.ConnPool) {
+	handler := healthHandler{conn: conn}
+
+	r.GET("/health/live", handler.Live)
+	r.GET("/health/ready", handler.Ready)
+}
+
+func (h *healthHandler) Live(ctx *fasthttp.RequestCtx) {
+	responses.SendResponseOK(map[string]string{"status": "ok"}, ctx)
+}
+
+func (h *healthHandler) Ready(ctx *fasthttp.RequestCtx) {
+	if h.conn == nil {
+		responses.SendResponseOK(readiness{Status: "ok", Postgres: "not used"}, ctx)
+		return
+	}
+
+	stat := h.conn.Stat()
+	inUse := stat.CheckedOutConnections()
+
+	status := readiness{
+		Status:   "ok",
+		Postgres: "ok",
+		Pool: poolStatus{
+			Max:        stat.MaxConnections,
+			Current:    stat.CurrentConnections,
+			Available:  stat.AvailableConnections,
+			InUse:      inUse,
+			Saturation: float64(inUse) / float64(stat.MaxConnections),
+		},
+	}
+
+	if err := h.ping(); err != nil {
+		// Driver errors may name hosts and users, so they only go to the log.
+		logger := requestid.Logger(ctx)
+		logger.Error().Err(err).Msg("readiness ping failed")
+
+		status.Status = "unavailable"
+		status.Postgres = "unavailable"
+		responses.SendResponse(fasthttp.StatusServiceUnavailable, status, ctx)
+		return
+	}
+
+	responses.SendResponseOK(status, ctx)
+}
+
+func (h *healthHandler) ping() error {
+	pingCtx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	conn, err := h.conn.AcquireEx(pingCtx)
+	if err != nil {
+		return err
+	}
+	defer h.conn.Release(conn)
+
+	return conn.Ping(pingCtx)
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	authModels "DbProjectForum/internal/app/auth/models"
+	userModels "DbProjectForum/internal/app/user/models"
+	"encoding/json"
+)
+
+type Stats struct {
+	Users        int64  `json:"users"`
+	Forums       int64  `json:"forums"`
+	Threads      int64  `json:"threads"`
+	Posts        int64  `json:"posts"`
+	Votes        int64  `json:"votes"`
+	UsersForum   int64  `json:"users_forum"`
+	DatabaseSize string `json:"database_size"`
+}
+
+// Record is one line of an export: Type names the table and Data holds the
+// row in the same JSON form the API uses.
+type Record struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+const (
+	RecordUser   = "user"
+	RecordAlias  = "alias"
+	RecordToken  = "api_token"
+	RecordForum  = "forum"
+	RecordMod    = "moderator"
+	RecordThread = "thread"
+	RecordPost   = "post"
+	RecordVote   = "vote"
+)
+
+// User is exported with its password hash and role, which the API model
+// hides. Sessions are not exported, so users log in again after an import.
+type User struct {
+	userModels.User
+	PasswordHash string          `json:"password_hash,omitempty"`
+	Role         userModels.Role `json:"role,omitempty"`
+}
+
+// Moderator is a user moderating a forum besides its owner.
+type Moderator struct {
+	Slug     string `json:"slug"`
+	Nickname string `json:"nickname"`
+}
+
+// Alias is a former nickname of a renamed user.
+type Alias struct {
+	Alias    string `json:"alias"`
+	Nickname string `json:"nickname"`
+}
+
+// APIToken is exported with its hash, so that bots keep working after an
+// import.
+type APIToken struct {
+	authModels.APIToken
+	TokenHash string `json:"token_hash"`
+}
+
+// Vote is exported with its thread, which the API model leaves out.
+type Vote struct {
+	Nickname string `json:"nickname"`
+	Voice    int32  `json:"voice"`
+	Thread   int64  `json:"thread"`
+}
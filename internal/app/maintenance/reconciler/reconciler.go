@@ -0,0 +1,76 @@
+package reconciler
+
+import (
+	"DbProjectForum/internal/app/maintenance"
+	"DbProjectForum/internal/pkg/metrics"
+	"context"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// Reconciler periodically recomputes forum.posts, forum.threads and
+// thread.votes and fixes the rows where the stored counters drifted.
+type Reconciler struct {
+	repo     maintenance.Repository
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func New(repo maintenance.Repository, interval time.Duration) *Reconciler {
+	return &Reconciler{
+		repo:     repo,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs a pass every interval until Stop is called.
+func (r *Reconciler) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.Run(context.Background())
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop waits for a pass in progress to finish.
+func (r *Reconciler) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// Run makes one pass over all counters.
+func (r *Reconciler) Run(ctx context.Context) {
+	forums, err := r.repo.RecountForums(ctx)
+	report("forum", forums, err)
+
+	threads, err := r.repo.RecountThreadVotes(ctx)
+	report("thread_votes", threads, err)
+}
+
+func report(counter string, corrected int64, err error) {
+	metrics.ReconcileRuns.WithLabelValues(counter, metrics.Outcome(err)).Inc()
+	if err != nil {
+		log.Error().Str("counter", counter).Msgf("reconciliation failed: %s", err)
+		return
+	}
+
+	metrics.ReconciledRows.WithLabelValues(counter).Add(float64(corrected))
+	if corrected != 0 {
+		log.Warn().Str("counter", counter).Int64("corrected", corrected).Msg("fixed drifted counters")
+		return
+	}
+	log.Debug().Str("counter", counter).Msg("counters are consistent")
+}
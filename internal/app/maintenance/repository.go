@@ -0,0 +1,21 @@
+package maintenance
+
+import (
+	"DbProjectForum/internal/app/maintenance/models"
+	"context"
+	"io"
+)
+
+// Repository repairs the denormalized data kept by triggers and moves whole
+// databases in and out. Each repair returns the number of rows it changed.
+type Repository interface {
+	RecountForums(ctx context.Context) (int64, error)
+	RecountThreadVotes(ctx context.Context) (int64, error)
+	RebuildUsersForum(ctx context.Context) (int64, error)
+	RebuildPaths(ctx context.Context, threadID int) (int64, error)
+
+	Stats(ctx context.Context) (models.Stats, error)
+
+	Export(ctx context.Context, w io.Writer) (int, error)
+	Import(ctx context.Context, r io.Reader) (int, error)
+}
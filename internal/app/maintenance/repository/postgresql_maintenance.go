@@ -0,0 +1,351 @@
+package repository
+
+import (
+	forumModels "DbProjectForum/internal/app/forum/models"
+	"DbProjectForum/internal/app/maintenance"
+	"DbProjectForum/internal/app/maintenance/models"
+	userModels "DbProjectForum/internal/app/user/models"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx"
+	"io"
+	"time"
+)
+
+// The recount queries compare the target row with the copy read from the
+// statement snapshot: a row changed by a transaction that commits while the
+// counts are taken is left alone instead of being overwritten with a stale
+// count, and is picked up by the next run.
+const (
+	recountForumsQuery = `UPDATE forum SET threads = COALESCE(t.n, 0), posts = COALESCE(p.n, 0)
+FROM forum f
+         LEFT JOIN (SELECT forum, COUNT(*) AS n FROM thread GROUP BY forum) t ON t.forum = f.slug
+         LEFT JOIN (SELECT forum, COUNT(*) AS n FROM post WHERE NOT isDeleted GROUP BY forum) p ON p.forum = f.slug
+WHERE forum.slug = f.slug
+  AND forum.threads = f.threads
+  AND forum.posts = f.posts
+  AND (forum.threads <> COALESCE(t.n, 0) OR forum.posts <> COALESCE(p.n, 0))`
+
+	recountThreadVotesQuery = `UPDATE thread SET votes = COALESCE(v.total, 0)
+FROM thread t
+         LEFT JOIN (SELECT idThread, SUM(voice) AS total FROM vote GROUP BY idThread) v ON v.idThread = t.id
+WHERE thread.id = t.id
+  AND thread.votes = t.votes
+  AND thread.votes <> COALESCE(v.total, 0)`
+
+	deleteStaleUsersForumQuery = `DELETE FROM users_forum
+WHERE NOT EXISTS(SELECT 1 FROM thread WHERE thread.author = users_forum.nickname AND thread.forum = users_forum.slug)
+  AND NOT EXISTS(SELECT 1 FROM post WHERE post.author = users_forum.nickname AND post.forum = users_forum.slug)`
+
+	insertMissingUsersForumQuery = `INSERT INTO users_forum(nickname, slug)
+SELECT author, forum FROM thread
+UNION
+SELECT author, forum FROM post
+ON CONFLICT DO NOTHING`
+
+	rebuildPathsQuery = `WITH RECURSIVE tree AS (
+    SELECT id, ARRAY [id] AS path FROM post WHERE thread = $1 AND parent IS NULL
+    UNION ALL
+    SELECT post.id, tree.path || post.id FROM post JOIN tree ON post.parent = tree.id WHERE post.thread = $1
+)
+UPDATE post SET path = tree.path
+FROM tree
+WHERE post.id = tree.id
+  AND post.path <> tree.path`
+
+	statsQuery = `SELECT (SELECT COUNT(*) FROM users), (SELECT COUNT(*) FROM forum), (SELECT COUNT(*) FROM thread),
+       (SELECT COUNT(*) FROM post), (SELECT COUNT(*) FROM vote), (SELECT COUNT(*) FROM users_forum),
+       pg_size_pretty(pg_database_size(current_database()))`
+
+	resetSequencesQuery = `SELECT setval(pg_get_serial_sequence('thread', 'id'), (SELECT MAX(id) FROM thread)),
+       setval(pg_get_serial_sequence('post', 'id'), (SELECT MAX(id) FROM post)),
+       setval(pg_get_serial_sequence('api_token', 'id'), (SELECT MAX(id) FROM api_token))`
+)
+
+// execer is the part of *pgx.ConnPool and *pgx.Tx the repairs need, so that
+// Import can run them inside its transaction.
+type execer interface {
+	Exec(sql string, arguments ...interface{}) (pgx.CommandTag, error)
+}
+
+type postgresMaintenanceRepository struct {
+	conn *pgx.ConnPool
+}
+
+func NewPostgresMaintenanceRepository(conn *pgx.ConnPool) maintenance.Repository {
+	return &postgresMaintenanceRepository{conn: conn}
+}
+
+func exec(conn execer, query string, args ...interface{}) (int64, error) {
+	tag, err := conn.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (p *postgresMaintenanceRepository) RecountForums(ctx context.Context) (int64, error) {
+	return exec(p.conn, recountForumsQuery)
+}
+
+func (p *postgresMaintenanceRepository) RecountThreadVotes(ctx context.Context) (int64, error) {
+	return exec(p.conn, recountThreadVotesQuery)
+}
+
+func (p *postgresMaintenanceRepository) RebuildUsersForum(ctx context.Context) (int64, error) {
+	tx, err := p.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	changed, err := rebuildUsersForum(tx)
+	if err != nil {
+		return 0, err
+	}
+	return changed, tx.Commit()
+}
+
+func rebuildUsersForum(conn execer) (int64, error) {
+	deleted, err := exec(conn, deleteStaleUsersForumQuery)
+	if err != nil {
+		return 0, err
+	}
+	inserted, err := exec(conn, insertMissingUsersForumQuery)
+	return deleted + inserted, err
+}
+
+func (p *postgresMaintenanceRepository) RebuildPaths(ctx context.Context, threadID int) (int64, error) {
+	return exec(p.conn, rebuildPathsQuery, threadID)
+}
+
+func (p *postgresMaintenanceRepository) Stats(ctx context.Context) (models.Stats, error) {
+	var stats models.Stats
+	err := p.conn.QueryRow(statsQuery).Scan(&stats.Users, &stats.Forums, &stats.Threads, &stats.Posts,
+		&stats.Votes, &stats.UsersForum, &stats.DatabaseSize)
+	return stats, err
+}
+
+// exportTable writes one record per row of query, converting rows with scan.
+func exportTable(tx *pgx.Tx, enc *json.Encoder, recordType, query string,
+	scan func(rows *pgx.Rows) (interface{}, error)) (int, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		row, err := scan(rows)
+		if err != nil {
+			return count, err
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return count, err
+		}
+		if err := enc.Encode(models.Record{Type: recordType, Data: data}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// Export writes the whole database as JSON lines, parents before children, from
+// a single snapshot. Timestamps keep their full precision.
+func (p *postgresMaintenanceRepository) Export(ctx context.Context, w io.Writer) (int, error) {
+	tx, err := p.conn.BeginEx(ctx, &pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+
+	tables := []struct {
+		recordType string
+		query      string
+		scan       func(rows *pgx.Rows) (interface{}, error)
+	}{
+		{models.RecordUser, `SELECT about, email, fullname, nickname, COALESCE(password_hash, ''), role FROM users
+ORDER BY nickname`,
+			func(rows *pgx.Rows) (interface{}, error) {
+				var user models.User
+				var role string
+				err := rows.Scan(&user.About, &user.Email, &user.FullName, &user.Nickname, &user.PasswordHash, &role)
+				user.Role = userModels.Role(role)
+				return user, err
+			}},
+		{models.RecordAlias, `SELECT alias, nickname FROM user_alias ORDER BY alias`,
+			func(rows *pgx.Rows) (interface{}, error) {
+				var alias models.Alias
+				err := rows.Scan(&alias.Alias, &alias.Nickname)
+				return alias, err
+			}},
+		{models.RecordToken, `SELECT id, token_hash, nickname, name, scopes, created, last_used FROM api_token
+ORDER BY id`,
+			func(rows *pgx.Rows) (interface{}, error) {
+				var token models.APIToken
+				err := rows.Scan(&token.ID, &token.TokenHash, &token.Nickname, &token.Name, &token.Scopes,
+					&token.Created, &token.LastUsed)
+				return token, err
+			}},
+		{models.RecordForum, `SELECT "user", posts, slug, threads, title FROM forum ORDER BY slug`,
+			func(rows *pgx.Rows) (interface{}, error) {
+				var forum forumModels.Forum
+				err := rows.Scan(&forum.User, &forum.Posts, &forum.Slug, &forum.Threads, &forum.Title)
+				return forum, err
+			}},
+		{models.RecordMod, `SELECT slug, nickname FROM forum_moderator ORDER BY slug, nickname`,
+			func(rows *pgx.Rows) (interface{}, error) {
+				var moderator models.Moderator
+				err := rows.Scan(&moderator.Slug, &moderator.Nickname)
+				return moderator, err
+			}},
+		{models.RecordThread, `SELECT author, created, forum, id, message, slug, title, votes FROM thread ORDER BY id`,
+			func(rows *pgx.Rows) (interface{}, error) {
+				var thread forumModels.Thread
+				var created time.Time
+				err := rows.Scan(&thread.Author, &created, &thread.Forum, &thread.Id, &thread.Message,
+					&thread.Slug, &thread.Title, &thread.Votes)
+				thread.Created = created.UTC().Format(time.RFC3339Nano)
+				return thread, err
+			}},
+		{models.RecordPost, `SELECT author, created, forum, id, isEdited, isDeleted, message, parent, thread FROM post
+ORDER BY id`,
+			func(rows *pgx.Rows) (interface{}, error) {
+				var post forumModels.Post
+				var created time.Time
+				err := rows.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.IsDeleted,
+					&post.Message, &post.Parent, &post.Thread)
+				post.Created = created.UTC().Format(time.RFC3339Nano)
+				return post, err
+			}},
+		{models.RecordVote, `SELECT nickname, voice, idThread FROM vote ORDER BY idThread, nickname`,
+			func(rows *pgx.Rows) (interface{}, error) {
+				var vote models.Vote
+				err := rows.Scan(&vote.Nickname, &vote.Voice, &vote.Thread)
+				return vote, err
+			}},
+	}
+
+	total := 0
+	for _, table := range tables {
+		count, err := exportTable(tx, enc, table.recordType, table.query, table.scan)
+		total += count
+		if err != nil {
+			return total, fmt.Errorf("export %s: %w", table.recordType, err)
+		}
+	}
+	return total, buf.Flush()
+}
+
+func importRecord(tx *pgx.Tx, record models.Record) error {
+	var err error
+	switch record.Type {
+	case models.RecordUser:
+		var user models.User
+		if err = json.Unmarshal(record.Data, &user); err == nil {
+			_, err = tx.Exec(`INSERT INTO users(about, email, fullname, nickname, password_hash, role)
+VALUES ($1, $2, $3, $4, NULLIF($5, ''), COALESCE(NULLIF($6, ''), 'member'))`,
+				user.About, user.Email, user.FullName, user.Nickname, user.PasswordHash, string(user.Role))
+		}
+	case models.RecordAlias:
+		var alias models.Alias
+		if err = json.Unmarshal(record.Data, &alias); err == nil {
+			_, err = tx.Exec(`INSERT INTO user_alias(alias, nickname) VALUES ($1, $2)`, alias.Alias, alias.Nickname)
+		}
+	case models.RecordToken:
+		var token models.APIToken
+		if err = json.Unmarshal(record.Data, &token); err == nil {
+			_, err = tx.Exec(`INSERT INTO api_token(id, token_hash, nickname, name, scopes, created, last_used)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				token.ID, token.TokenHash, token.Nickname, token.Name, token.Scopes, token.Created, token.LastUsed)
+		}
+	case models.RecordForum:
+		var forum forumModels.Forum
+		if err = json.Unmarshal(record.Data, &forum); err == nil {
+			_, err = tx.Exec(`INSERT INTO forum("user", slug, title) VALUES ($1, $2, $3)`,
+				forum.User, forum.Slug, forum.Title)
+		}
+	case models.RecordMod:
+		var moderator models.Moderator
+		if err = json.Unmarshal(record.Data, &moderator); err == nil {
+			_, err = tx.Exec(`INSERT INTO forum_moderator(slug, nickname) VALUES ($1, $2)`,
+				moderator.Slug, moderator.Nickname)
+		}
+	case models.RecordThread:
+		var thread forumModels.Thread
+		if err = json.Unmarshal(record.Data, &thread); err == nil {
+			_, err = tx.Exec(`INSERT INTO thread(author, created, forum, id, message, slug, title)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				thread.Author, thread.Created, thread.Forum, thread.Id, thread.Message, thread.Slug, thread.Title)
+		}
+	case models.RecordPost:
+		var post forumModels.Post
+		if err = json.Unmarshal(record.Data, &post); err == nil {
+			_, err = tx.Exec(`INSERT INTO post(author, created, forum, id, isEdited, isDeleted, message, parent, thread)
+VALUES ($1, $2, $3, $4, $5, $6, $7, nullif($8, 0), $9)`,
+				post.Author, post.Created, post.Forum, post.Id, post.IsEdited, post.IsDeleted, post.Message, post.Parent,
+				post.Thread)
+		}
+	case models.RecordVote:
+		var vote models.Vote
+		if err = json.Unmarshal(record.Data, &vote); err == nil {
+			_, err = tx.Exec(`INSERT INTO vote(nickname, voice, idThread) VALUES ($1, $2, $3)`,
+				vote.Nickname, vote.Voice, vote.Thread)
+		}
+	default:
+		err = fmt.Errorf("unknown record type %q", record.Type)
+	}
+	return err
+}
+
+// Import loads an export in one transaction. Paths are computed by the
+// insert trigger, so posts must come after their parents as Export writes
+// them; counters, users_forum and the id sequences are recomputed at the end.
+func (p *postgresMaintenanceRepository) Import(ctx context.Context, r io.Reader) (int, error) {
+	tx, err := p.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	reader := bufio.NewReader(r)
+	count := 0
+	for lineNo := 1; ; lineNo++ {
+		line, readErr := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) != 0 {
+			var record models.Record
+			if err := json.Unmarshal(line, &record); err != nil {
+				return count, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if err := importRecord(tx, record); err != nil {
+				return count, fmt.Errorf("line %d: %s: %w", lineNo, record.Type, err)
+			}
+			count++
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return count, readErr
+		}
+	}
+
+	for _, query := range []string{recountForumsQuery, recountThreadVotesQuery, resetSequencesQuery} {
+		if _, err := exec(tx, query); err != nil {
+			return count, err
+		}
+	}
+	if _, err := rebuildUsersForum(tx); err != nil {
+		return count, err
+	}
+	return count, tx.Commit()
+}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx"
+	"io"
+	"strconv"
+)
+
+const Usage = `migrate up                apply pending migrations
+migrate down [steps]      revert the latest migrations, one by default
+migrate status            list migrations and when they were applied
+migrate baseline VERSION  mark migrations up to VERSION as applied without running them`
+
+// Command runs the migrate subcommand described by Usage, where args follow
+// the word "migrate", and reports what it did to out.
+func Command(conn *pgx.Conn, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage:\n" + Usage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := Up(conn)
+		for _, migration := range applied {
+			fmt.Fprintf(out, "applied %s\n", migration)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := Down(conn, steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(out, "reverted %s\n", migration)
+		}
+		return err
+	case "status":
+		statuses, err := List(conn)
+		for _, status := range statuses {
+			if status.Applied {
+				fmt.Fprintf(out, "%s applied %s\n", status.Migration, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Fprintf(out, "%s pending\n", status.Migration)
+			}
+		}
+		return err
+	case "baseline":
+		if len(args) < 2 {
+			return errors.New("baseline needs a version")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return Baseline(conn, version)
+	default:
+		return fmt.Errorf("unknown migrate command %q, usage:\n%s", args[0], Usage)
+	}
+}
@@ -0,0 +1,219 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"github.com/jackc/pgx"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey identifies the advisory lock held while migrations run, so that
+// several instances starting at once apply each migration only once.
+const lockKey = 7243001
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change read from sql/<version>_<name>.{up,down}.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// All returns the embedded migrations ordered by version.
+func All() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		data, err := files.ReadFile("sql/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns the ones it applied.
+func Up(conn *pgx.Conn) ([]Migration, error) {
+	var applied []Migration
+	err := locked(conn, func(done map[int]time.Time, migrations []Migration) error {
+		for _, migration := range migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			err := inTx(conn, migration.Up,
+				`INSERT INTO schema_migrations(version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations and returns them.
+func Down(conn *pgx.Conn, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := locked(conn, func(done map[int]time.Time, migrations []Migration) error {
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			err := inTx(conn, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Baseline records every migration up to version as applied without running
+// it, for databases created before migrations were tracked.
+func Baseline(conn *pgx.Conn, version int) error {
+	return locked(conn, func(done map[int]time.Time, migrations []Migration) error {
+		for _, migration := range migrations {
+			if _, ok := done[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			_, err := conn.Exec(`INSERT INTO schema_migrations(version, name) VALUES ($1, $2)`,
+				migration.Version, migration.Name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// List reports which of the embedded migrations are applied.
+func List(conn *pgx.Conn) ([]Status, error) {
+	var statuses []Status
+	err := locked(conn, func(done map[int]time.Time, migrations []Migration) error {
+		for _, migration := range migrations {
+			appliedAt, ok := done[migration.Version]
+			statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// locked runs f under the advisory lock with the embedded migrations and the
+// versions already applied, creating the bookkeeping table on first use.
+func locked(conn *pgx.Conn, f func(done map[int]time.Time, migrations []Migration) error) error {
+	migrations, err := All()
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.Exec(`SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return err
+	}
+	defer conn.Exec(`SELECT pg_advisory_unlock($1)`, lockKey)
+
+	_, err = conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version    INT PRIMARY KEY,
+    name       text        NOT NULL,
+    applied_at timestamptz NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := conn.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		done[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return f(done, migrations)
+}
+
+func inTx(conn *pgx.Conn, script, record string, args ...interface{}) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS users_forum, vote, post, thread, forum, "users";
+
+DROP FUNCTION IF EXISTS update_user_forum();
+DROP FUNCTION IF EXISTS update_path();
+DROP FUNCTION IF EXISTS insert_votes();
+DROP FUNCTION IF EXISTS update_votes();
+DROP FUNCTION IF EXISTS update_threads_count();
@@ -1,16 +1,5 @@
 CREATE EXTENSION IF NOT EXISTS citext;
 
-ALTER SYSTEM SET
-    checkpoint_completion_target = '0.9';
-ALTER SYSTEM SET
-    wal_buffers = '6912kB';
-ALTER SYSTEM SET
-    default_statistics_target = '100';
-ALTER SYSTEM SET
-    random_page_cost = '1.1';
-ALTER SYSTEM SET
-    effective_io_concurrency = '200';
-
 CREATE UNLOGGED TABLE "users"
 (
     About    text,
@@ -85,7 +74,6 @@ BEGIN
 
         NEW.path := NEW.path || parent_path || new.id;
     end if;
-    UPDATE forum SET Posts=Posts + 1 WHERE lower(forum.slug) = lower(new.forum);
     RETURN new;
 end
 $update_path$ LANGUAGE plpgsql;
@@ -141,12 +129,6 @@ CREATE TRIGGER thread_insert_user_forum
     FOR EACH ROW
 EXECUTE PROCEDURE update_user_forum();
 
-CREATE TRIGGER post_insert_user_forum
-    AFTER INSERT
-    ON post
-    FOR EACH ROW
-EXECUTE PROCEDURE update_user_forum();
-
 CREATE TRIGGER path_update_trigger
     BEFORE INSERT
     ON post
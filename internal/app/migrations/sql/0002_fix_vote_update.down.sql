@@ -0,0 +1,7 @@
+CREATE OR REPLACE FUNCTION update_votes() RETURNS TRIGGER AS
+$update_users_forum$
+BEGIN
+    UPDATE thread SET votes=(votes+NEW.voice*2) WHERE id=NEW.idThread;
+    return NEW;
+end
+$update_users_forum$ LANGUAGE plpgsql;
@@ -0,0 +1,9 @@
+CREATE OR REPLACE FUNCTION update_votes() RETURNS TRIGGER AS
+$update_users_forum$
+BEGIN
+    IF NEW.voice <> OLD.voice THEN
+        UPDATE thread SET votes=(votes+NEW.voice-OLD.voice) WHERE id=NEW.idThread;
+    END IF;
+    return NEW;
+end
+$update_users_forum$ LANGUAGE plpgsql;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS thread_search_index;
+DROP INDEX IF EXISTS post_search_index;
+
+ALTER TABLE thread DROP COLUMN IF EXISTS search;
+ALTER TABLE post DROP COLUMN IF EXISTS search;
@@ -0,0 +1,10 @@
+ALTER TABLE thread
+    ADD COLUMN search tsvector GENERATED ALWAYS AS (
+            setweight(to_tsvector('simple', COALESCE(title, '')), 'A') ||
+            setweight(to_tsvector('simple', COALESCE(message, '')), 'B')) STORED;
+
+ALTER TABLE post
+    ADD COLUMN search tsvector GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(message, ''))) STORED;
+
+CREATE INDEX thread_search_index ON thread USING GIN (search);
+CREATE INDEX post_search_index ON post USING GIN (search);
@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS user_alias;
+
+ALTER TABLE forum
+    DROP CONSTRAINT forum_user_fkey,
+    ADD CONSTRAINT forum_user_fkey FOREIGN KEY ("user") REFERENCES "users" (nickname);
+ALTER TABLE thread
+    DROP CONSTRAINT thread_author_fkey,
+    ADD CONSTRAINT thread_author_fkey FOREIGN KEY (author) REFERENCES "users" (nickname);
+ALTER TABLE post
+    DROP CONSTRAINT post_author_fkey,
+    ADD CONSTRAINT post_author_fkey FOREIGN KEY (author) REFERENCES "users" (nickname);
+ALTER TABLE vote
+    DROP CONSTRAINT vote_nickname_fkey,
+    ADD CONSTRAINT vote_nickname_fkey FOREIGN KEY (nickname) REFERENCES "users" (nickname);
+ALTER TABLE users_forum
+    DROP CONSTRAINT users_forum_nickname_fkey,
+    ADD CONSTRAINT users_forum_nickname_fkey FOREIGN KEY (nickname) REFERENCES "users" (nickname);
@@ -0,0 +1,26 @@
+-- Renaming a user updates every copy of the nickname.
+ALTER TABLE forum
+    DROP CONSTRAINT forum_user_fkey,
+    ADD CONSTRAINT forum_user_fkey FOREIGN KEY ("user") REFERENCES "users" (nickname) ON UPDATE CASCADE;
+ALTER TABLE thread
+    DROP CONSTRAINT thread_author_fkey,
+    ADD CONSTRAINT thread_author_fkey FOREIGN KEY (author) REFERENCES "users" (nickname) ON UPDATE CASCADE;
+ALTER TABLE post
+    DROP CONSTRAINT post_author_fkey,
+    ADD CONSTRAINT post_author_fkey FOREIGN KEY (author) REFERENCES "users" (nickname) ON UPDATE CASCADE;
+ALTER TABLE vote
+    DROP CONSTRAINT vote_nickname_fkey,
+    ADD CONSTRAINT vote_nickname_fkey FOREIGN KEY (nickname) REFERENCES "users" (nickname) ON UPDATE CASCADE;
+ALTER TABLE users_forum
+    DROP CONSTRAINT users_forum_nickname_fkey,
+    ADD CONSTRAINT users_forum_nickname_fkey FOREIGN KEY (nickname) REFERENCES "users" (nickname) ON UPDATE CASCADE;
+
+-- Former nicknames of renamed users.
+CREATE UNLOGGED TABLE user_alias
+(
+    alias    citext PRIMARY KEY,
+    nickname citext NOT NULL,
+    FOREIGN KEY (nickname) REFERENCES "users" (nickname) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX user_alias_nickname_index ON user_alias (nickname);
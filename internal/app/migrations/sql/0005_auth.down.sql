@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS session;
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
@@ -0,0 +1,16 @@
+-- bcrypt hash of the password; accounts created before passwords have none
+-- and can't log in.
+ALTER TABLE users
+    ADD COLUMN password_hash text;
+
+-- Login sessions, looked up by the SHA-256 hash of the token.
+CREATE UNLOGGED TABLE session
+(
+    token_hash text PRIMARY KEY,
+    nickname   citext                   NOT NULL,
+    created    timestamp with time zone NOT NULL DEFAULT now(),
+    expires    timestamp with time zone NOT NULL,
+    FOREIGN KEY (nickname) REFERENCES "users" (nickname) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX session_nickname_index ON session (nickname);
@@ -0,0 +1,15 @@
+-- Personal API tokens, looked up by the SHA-256 hash of the token like
+-- sessions. last_used is null until the token is first used.
+CREATE UNLOGGED TABLE api_token
+(
+    id         serial PRIMARY KEY,
+    token_hash text                     NOT NULL UNIQUE,
+    nickname   citext                   NOT NULL,
+    name       text                     NOT NULL,
+    scopes     text[]                   NOT NULL,
+    created    timestamp with time zone NOT NULL DEFAULT now(),
+    last_used  timestamp with time zone,
+    FOREIGN KEY (nickname) REFERENCES "users" (nickname) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX api_token_nickname_index ON api_token (nickname);
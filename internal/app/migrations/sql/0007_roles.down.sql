@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS forum_moderator;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,15 @@
+-- Site role of a user. Moderators are per forum and kept apart.
+ALTER TABLE users
+    ADD COLUMN role text NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member', 'banned'));
+
+-- Moderators of a forum besides its owner, who always moderates it.
+CREATE UNLOGGED TABLE forum_moderator
+(
+    slug     citext NOT NULL,
+    nickname citext NOT NULL,
+    PRIMARY KEY (slug, nickname),
+    FOREIGN KEY (slug) REFERENCES forum (slug) ON DELETE CASCADE,
+    FOREIGN KEY (nickname) REFERENCES "users" (nickname) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX forum_moderator_nickname_index ON forum_moderator (nickname);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS post_deleted_index;
+
+ALTER TABLE post DROP COLUMN IF EXISTS isDeleted;
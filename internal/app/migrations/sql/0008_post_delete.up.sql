@@ -0,0 +1,6 @@
+-- Deleted posts stay as tombstones so that paths and parents of their
+-- replies keep pointing somewhere. forum.posts doesn't count them.
+ALTER TABLE post
+    ADD COLUMN isDeleted boolean NOT NULL DEFAULT false;
+
+CREATE INDEX post_deleted_index ON post (id) WHERE isDeleted;
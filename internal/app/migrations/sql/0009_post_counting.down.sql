@@ -0,0 +1,27 @@
+CREATE OR REPLACE FUNCTION update_path() RETURNS TRIGGER AS
+$update_path$
+DECLARE
+    parent_path         BIGINT[];
+    first_parent_thread INT;
+BEGIN
+    IF (NEW.parent IS NULL) THEN
+        NEW.path := array_append(new.path, new.id);
+    ELSE
+        SELECT path FROM post WHERE id = new.parent INTO parent_path;
+        SELECT thread FROM post WHERE id = parent_path[1] INTO first_parent_thread;
+        IF NOT FOUND OR first_parent_thread != NEW.thread THEN
+            RAISE EXCEPTION 'parent is from different thread' USING ERRCODE = '00409';
+        end if;
+
+        NEW.path := NEW.path || parent_path || new.id;
+    end if;
+    UPDATE forum SET Posts=Posts + 1 WHERE lower(forum.slug) = lower(new.forum);
+    RETURN new;
+end
+$update_path$ LANGUAGE plpgsql;
+
+CREATE TRIGGER post_insert_user_forum
+    AFTER INSERT
+    ON post
+    FOR EACH ROW
+EXECUTE PROCEDURE update_user_forum();
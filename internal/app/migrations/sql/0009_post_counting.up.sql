@@ -0,0 +1,24 @@
+-- AddPosts counts new posts in forum.posts and fills users_forum itself, once
+-- per batch, so the per-row trigger work that did the same goes away.
+CREATE OR REPLACE FUNCTION update_path() RETURNS TRIGGER AS
+$update_path$
+DECLARE
+    parent_path         BIGINT[];
+    first_parent_thread INT;
+BEGIN
+    IF (NEW.parent IS NULL) THEN
+        NEW.path := array_append(new.path, new.id);
+    ELSE
+        SELECT path FROM post WHERE id = new.parent INTO parent_path;
+        SELECT thread FROM post WHERE id = parent_path[1] INTO first_parent_thread;
+        IF NOT FOUND OR first_parent_thread != NEW.thread THEN
+            RAISE EXCEPTION 'parent is from different thread' USING ERRCODE = '00409';
+        end if;
+
+        NEW.path := NEW.path || parent_path || new.id;
+    end if;
+    RETURN new;
+end
+$update_path$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS post_insert_user_forum ON post;
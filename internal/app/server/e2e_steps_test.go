@@ -0,0 +1,440 @@
+package server_test
+
+const errorBody = `{"message":"*"}`
+
+// admin is listed in the admins setting by TestEndToEnd, the way that
+// setting lets the first admin in.
+const admin = "root"
+
+// steps run in order and build on each other, starting from an empty database.
+var steps = []step{
+	{"status without login", "GET", "/api/service/status", "", 401, errorBody},
+	{"clear without login", "POST", "/api/service/clear", "", 401, errorBody},
+
+	// users
+	{"create user", "POST", "/api/user/alice/create",
+		`{"fullname":"Alice","email":"alice@example.com","about":"first"}`, 201,
+		`{"nickname":"alice","fullname":"Alice","email":"alice@example.com","about":"first"}`},
+	{"create second user", "POST", "/api/user/Bob/create",
+		`{"fullname":"Bob","email":"bob@example.com","about":""}`, 201,
+		`{"nickname":"Bob"}`},
+	{"create user with taken nickname", "POST", "/api/user/ALICE/create",
+		`{"fullname":"Other","email":"other@example.com","about":""}`, 409,
+		`[{"nickname":"alice"}]`},
+	{"create user with taken nickname and email", "POST", "/api/user/alice/create",
+		`{"fullname":"Other","email":"BOB@example.com","about":""}`, 409,
+		`[{"nickname":"alice"},{"nickname":"Bob"}]`},
+	{"create user with another nickname in the body", "POST", "/api/user/alice/create",
+		`{"nickname":"newbie","fullname":"Newbie","email":"newbie@example.com","about":""}`, 409,
+		`[{"nickname":"alice"}]`},
+	{"create user with broken body", "POST", "/api/user/carol/create", `{"fullname":`, 400, errorBody},
+	{"get user", "GET", "/api/user/ALICE/profile", "", 200,
+		`{"nickname":"alice","email":"alice@example.com"}`},
+	{"get missing user", "GET", "/api/user/nobody/profile", "", 404, errorBody},
+	{"update user", "POST", "/api/user/alice/profile", `{"fullname":"Alice A"}`, 200,
+		`{"nickname":"alice","fullname":"Alice A","about":"first"}`},
+	{"update user with empty body", "POST", "/api/user/alice/profile", `{}`, 200,
+		`{"fullname":"Alice A"}`},
+	{"update user to taken email", "POST", "/api/user/alice/profile", `{"email":"bob@example.com"}`, 409,
+		errorBody},
+	{"update missing user", "POST", "/api/user/nobody/profile", `{"about":"x"}`, 404, errorBody},
+	{"list users", "GET", "/api/users", "", 200, `[{"nickname":"alice"},{"nickname":"Bob"}]`},
+	{"list users desc", "GET", "/api/users?desc=true", "", 200, `[{"nickname":"Bob"},{"nickname":"alice"}]`},
+	{"list users since", "GET", "/api/users?since=ALICE&limit=1", "", 200, `[{"nickname":"Bob"}]`},
+	{"list users by nickname prefix", "GET", "/api/users?prefix=b", "", 200, `[{"nickname":"Bob"}]`},
+	{"list users by fullname prefix", "GET", "/api/users?prefix=alice%20a", "", 200, `[{"nickname":"alice"}]`},
+	{"list users with wildcard prefix", "GET", "/api/users?prefix=%25", "", 200, `[]`},
+	{"list users with bad limit", "GET", "/api/users?limit=x", "", 400, errorBody},
+
+	// forums
+	{"create forum", "POST", "/api/forum/create",
+		`{"slug":"e2e-forum","title":"End to end","user":"ALICE"}`, 201,
+		`{"slug":"e2e-forum","title":"End to end","user":"alice","posts":0,"threads":0}`},
+	{"create forum with taken slug", "POST", "/api/forum/create",
+		`{"slug":"E2E-Forum","title":"Other","user":"Bob"}`, 409,
+		`{"slug":"e2e-forum","title":"End to end"}`},
+	{"create forum for missing user", "POST", "/api/forum/create",
+		`{"slug":"orphan","title":"Orphan","user":"nobody"}`, 404, errorBody},
+	{"get forum", "GET", "/api/forum/E2E-FORUM/details", "", 200, `{"slug":"e2e-forum"}`},
+	{"get missing forum", "GET", "/api/forum/missing/details", "", 404, errorBody},
+
+	// threads
+	{"create thread", "POST", "/api/forum/e2e-forum/create",
+		`{"slug":"first","title":"First","author":"bob","message":"hello","created":"2020-01-01T00:00:00.000Z"}`, 201,
+		`{"id":1,"slug":"first","forum":"e2e-forum","author":"bob","votes":0,"created":"2020-01-01T00:00:00.000Z"}`},
+	{"create thread without slug", "POST", "/api/forum/E2E-forum/create",
+		`{"title":"Second","author":"alice","message":"again","created":"2020-02-01T00:00:00.000Z"}`, 201,
+		`{"id":2,"slug":null,"forum":"e2e-forum"}`},
+	{"create thread with taken slug", "POST", "/api/forum/e2e-forum/create",
+		`{"slug":"FIRST","title":"Copy","author":"alice","message":"copy"}`, 409,
+		`{"id":1,"title":"First"}`},
+	{"create thread in missing forum", "POST", "/api/forum/missing/create",
+		`{"title":"Lost","author":"alice","message":"lost"}`, 404, errorBody},
+	{"create thread for missing author", "POST", "/api/forum/e2e-forum/create",
+		`{"title":"Lost","author":"nobody","message":"lost"}`, 404, errorBody},
+	{"forum counts threads", "GET", "/api/forum/e2e-forum/details", "", 200, `{"threads":2}`},
+	{"list threads", "GET", "/api/forum/e2e-forum/threads", "", 200, `[{"id":1},{"id":2}]`},
+	{"list threads desc with limit", "GET", "/api/forum/e2e-forum/threads?desc=true&limit=1", "", 200,
+		`[{"id":2}]`},
+	{"list threads since", "GET", "/api/forum/e2e-forum/threads?since=2020-01-15T00:00:00.000Z", "", 200,
+		`[{"id":2}]`},
+	{"list threads since desc", "GET",
+		"/api/forum/e2e-forum/threads?since=2020-01-15T00:00:00.000Z&desc=true", "", 200, `[{"id":1}]`},
+	{"list threads of missing forum", "GET", "/api/forum/missing/threads", "", 404, errorBody},
+	{"list threads with bad limit", "GET", "/api/forum/e2e-forum/threads?limit=many", "", 400, errorBody},
+	{"get thread by slug", "GET", "/api/thread/FIRST/details", "", 200, `{"id":1,"slug":"first"}`},
+	{"get thread by id", "GET", "/api/thread/2/details", "", 200, `{"id":2,"title":"Second"}`},
+	{"get missing thread by slug", "GET", "/api/thread/missing/details", "", 404, errorBody},
+	{"get missing thread by id", "GET", "/api/thread/999/details", "", 404, errorBody},
+	{"update thread", "POST", "/api/thread/first/details", `{"title":"Renamed"}`, 200,
+		`{"id":1,"title":"Renamed","message":"hello"}`},
+	{"update thread by id", "POST", "/api/thread/2/details", `{"message":"changed"}`, 200,
+		`{"id":2,"title":"Second","message":"changed"}`},
+	{"update missing thread", "POST", "/api/thread/999/details", `{"title":"x"}`, 404, errorBody},
+
+	// posts
+	{"create root posts", "POST", "/api/thread/first/create",
+		`[{"author":"alice","message":"root one"},{"author":"Bob","message":"root two"}]`, 201,
+		`[{"id":1,"thread":1,"forum":"e2e-forum","isEdited":false},{"id":2,"thread":1}]`},
+	{"create child post", "POST", "/api/thread/1/create",
+		`[{"author":"alice","message":"child","parent":1}]`, 201,
+		`[{"id":3,"parent":1,"thread":1}]`},
+	{"create no posts", "POST", "/api/thread/1/create", `[]`, 201, `[]`},
+	{"create post with parent in other thread", "POST", "/api/thread/2/create",
+		`[{"author":"alice","message":"wrong","parent":1}]`, 409, errorBody},
+	{"create post for missing author", "POST", "/api/thread/1/create",
+		`[{"author":"nobody","message":"lost"}]`, 404, errorBody},
+	{"create post in missing thread", "POST", "/api/thread/999/create",
+		`[{"author":"alice","message":"lost"}]`, 404, errorBody},
+	{"forum counts posts", "GET", "/api/forum/e2e-forum/details", "", 200, `{"posts":3}`},
+	{"flat posts", "GET", "/api/thread/first/posts?sort=flat", "", 200, `[{"id":1},{"id":2},{"id":3}]`},
+	{"flat posts desc since", "GET", "/api/thread/1/posts?sort=flat&desc=true&since=3", "", 200,
+		`[{"id":2},{"id":1}]`},
+	{"tree posts", "GET", "/api/thread/first/posts?sort=tree", "", 200, `[{"id":1},{"id":3},{"id":2}]`},
+	{"tree posts desc", "GET", "/api/thread/1/posts?sort=tree&desc=true", "", 200,
+		`[{"id":2},{"id":3},{"id":1}]`},
+	{"tree posts since", "GET", "/api/thread/1/posts?sort=tree&since=3&limit=5", "", 200, `[{"id":2}]`},
+	{"parent tree posts", "GET", "/api/thread/1/posts?sort=parent_tree&limit=1", "", 200,
+		`[{"id":1},{"id":3}]`},
+	{"parent tree posts desc", "GET", "/api/thread/1/posts?sort=parent_tree&desc=true", "", 200,
+		`[{"id":2},{"id":1},{"id":3}]`},
+	{"parent tree posts since", "GET", "/api/thread/1/posts?sort=parent_tree&since=1", "", 200,
+		`[{"id":2}]`},
+	{"posts of empty thread", "GET", "/api/thread/2/posts", "", 200, `[]`},
+	{"posts with unknown sort", "GET", "/api/thread/1/posts?sort=random", "", 400, errorBody},
+	{"posts of missing thread by id", "GET", "/api/thread/999/posts", "", 404, errorBody},
+	{"posts of missing thread by slug", "GET", "/api/thread/missing/posts", "", 404, errorBody},
+	{"get post", "GET", "/api/post/3/details", "", 200, `{"post":{"id":3,"message":"child"}}`},
+	{"get post with related", "GET", "/api/post/3/details?related=user,thread,forum", "", 200,
+		`{"post":{"id":3},"author":{"nickname":"alice"},"thread":{"id":1},"forum":{"slug":"e2e-forum"}}`},
+	{"get missing post", "GET", "/api/post/999/details", "", 404, errorBody},
+	{"update post with same message", "POST", "/api/post/3/details", `{"message":"child"}`, 200,
+		`{"id":3,"isEdited":false}`},
+	{"update post", "POST", "/api/post/3/details", `{"message":"edited"}`, 200,
+		`{"id":3,"message":"edited","isEdited":true}`},
+	{"update post with empty body", "POST", "/api/post/3/details", `{}`, 200,
+		`{"id":3,"message":"edited","isEdited":true}`},
+	{"update missing post", "POST", "/api/post/999/details", `{"message":"x"}`, 404, errorBody},
+
+	// votes
+	{"vote", "POST", "/api/thread/first/vote", `{"nickname":"alice","voice":1}`, 200, `{"id":1,"votes":1}`},
+	{"vote by id", "POST", "/api/thread/1/vote", `{"nickname":"bob","voice":-1}`, 200, `{"votes":0}`},
+	{"change vote", "POST", "/api/thread/FIRST/vote", `{"nickname":"ALICE","voice":-1}`, 200,
+		`{"votes":-2}`},
+	{"repeat vote", "POST", "/api/thread/1/vote", `{"nickname":"alice","voice":-1}`, 200, `{"votes":-2}`},
+	{"vote by missing user", "POST", "/api/thread/1/vote", `{"nickname":"nobody","voice":1}`, 404,
+		errorBody},
+	{"vote in missing thread", "POST", "/api/thread/999/vote", `{"nickname":"alice","voice":1}`, 404,
+		errorBody},
+	{"vote in missing thread by slug", "POST", "/api/thread/missing/vote", `{"nickname":"alice","voice":1}`,
+		404, errorBody},
+
+	// forum users
+	{"forum users", "GET", "/api/forum/e2e-forum/users", "", 200,
+		`[{"nickname":"alice"},{"nickname":"Bob"}]`},
+	{"forum users desc", "GET", "/api/forum/e2e-forum/users?desc=true", "", 200,
+		`[{"nickname":"Bob"},{"nickname":"alice"}]`},
+	{"forum users since", "GET", "/api/forum/e2e-forum/users?since=ALICE", "", 200, `[{"nickname":"Bob"}]`},
+	{"forum users with limit", "GET", "/api/forum/e2e-forum/users?limit=1", "", 200,
+		`[{"nickname":"alice"}]`},
+	{"users of missing forum", "GET", "/api/forum/missing/users", "", 404, errorBody},
+
+	// search
+	{"search posts", "GET", "/api/search?q=root&type=post", "", 200,
+		`[{"type":"post","post":{"id":1},"snippet":"*"},{"type":"post","post":{"id":2},"snippet":"*"}]`},
+	{"search posts desc", "GET", "/api/search?q=root&type=post&desc=true", "", 200,
+		`[{"post":{"id":2}},{"post":{"id":1}}]`},
+	{"search threads", "GET", "/api/search?q=HELLO&type=thread", "", 200,
+		`[{"type":"thread","thread":{"id":1,"title":"Renamed"},"snippet":"*"}]`},
+	{"search both", "GET", "/api/search?q=changed", "", 200, `[{"type":"thread","thread":{"id":2}}]`},
+	{"search by author", "GET", "/api/search?q=root&author=bob", "", 200, `[{"post":{"id":2}}]`},
+	{"search in forum", "GET", "/api/search?q=root&forum=E2E-FORUM&limit=1", "", 200, `[{"post":{"id":1}}]`},
+	{"search without match", "GET", "/api/search?q=nothing", "", 200, `[]`},
+	{"search without query", "GET", "/api/search", "", 400, errorBody},
+	{"search unknown type", "GET", "/api/search?q=root&type=user", "", 400, errorBody},
+	{"search in missing forum", "GET", "/api/search?q=root&forum=missing", "", 404, errorBody},
+	{"search by missing author", "GET", "/api/search?q=root&author=nobody", "", 404, errorBody},
+
+	// admin
+	{"create admin", "POST", "/api/user/root/create",
+		`{"fullname":"Root","email":"root@example.com","about":"","password":"correct horse"}`, 201, ""},
+
+	// export
+	{"export without login", "GET", "/api/user/alice/export", "", 401, errorBody},
+	{"login to export", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"export user", "GET", "/api/user/ALICE/export", "", 200,
+		`{"user":{"nickname":"alice","fullname":"Alice A"},"forums":[{"slug":"e2e-forum"}],` +
+			`"threads":[{"id":2,"author":"alice"}],"posts":[{"id":1},{"id":3,"message":"edited"}],` +
+			`"votes":[{"thread":1,"voice":-1}]}`},
+	{"export missing user", "GET", "/api/user/nobody/export", "", 404, errorBody},
+	{"logout after export", "POST", "/api/auth/logout", "", 200, ""},
+
+	// rename users
+	{"create user to rename", "POST", "/api/user/erin/create",
+		`{"fullname":"Erin","email":"erin@example.com","about":""}`, 201, `{"nickname":"erin"}`},
+	{"vote before rename", "POST", "/api/thread/2/vote", `{"nickname":"erin","voice":1}`, 200, `{"votes":1}`},
+	{"rename without login", "POST", "/api/user/erin/rename", `{"nickname":"frank"}`, 401, errorBody},
+	{"delete without login", "DELETE", "/api/user/erin/profile", "", 401, errorBody},
+	{"login to rename", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"rename user", "POST", "/api/user/ERIN/rename", `{"nickname":"frank"}`, 200,
+		`{"nickname":"frank","fullname":"Erin"}`},
+	{"old nickname redirects", "GET", "/api/user/erin/profile", "", 301, `{"nickname":"frank"}`},
+	{"rename to taken nickname", "POST", "/api/user/frank/rename", `{"nickname":"BOB"}`, 409, errorBody},
+	{"rename missing user", "POST", "/api/user/nobody/rename", `{"nickname":"somebody"}`, 404, errorBody},
+	{"rename without nickname", "POST", "/api/user/frank/rename", `{}`, 400, errorBody},
+	{"rename case only", "POST", "/api/user/frank/rename", `{"nickname":"Frank"}`, 200, `{"nickname":"Frank"}`},
+	{"rename back", "POST", "/api/user/frank/rename", `{"nickname":"erin"}`, 200, `{"nickname":"erin"}`},
+	{"former alias is a user again", "GET", "/api/user/erin/profile", "", 200, `{"nickname":"erin"}`},
+	{"second nickname redirects", "GET", "/api/user/FRANK/profile", "", 301, `{"nickname":"erin"}`},
+	{"rename keeps votes", "GET", "/api/user/erin/export", "", 200, `{"votes":[{"thread":2,"voice":1}]}`},
+	{"delete renamed user", "DELETE", "/api/user/erin/profile?mode=cascade", "", 200, `{"votes":1}`},
+	{"alias of deleted user is gone", "GET", "/api/user/frank/profile", "", 404, errorBody},
+	{"logout after renames", "POST", "/api/auth/logout", "", 200, ""},
+
+	// delete users
+	{"create user to cascade", "POST", "/api/user/dave/create",
+		`{"fullname":"Dave","email":"dave@example.com","about":""}`, 201, `{"nickname":"dave"}`},
+	{"create user to anonymize", "POST", "/api/user/carol/create",
+		`{"fullname":"Carol","email":"carol@example.com","about":""}`, 201, `{"nickname":"carol"}`},
+	{"create forum to cascade", "POST", "/api/forum/create",
+		`{"slug":"dave-forum","title":"Dave","user":"dave"}`, 201, `{"slug":"dave-forum"}`},
+	{"create thread in forum to cascade", "POST", "/api/forum/dave-forum/create",
+		`{"title":"Guest","author":"carol","message":"guest"}`, 201, `{"id":3}`},
+	{"create post in forum to cascade", "POST", "/api/thread/3/create",
+		`[{"author":"carol","message":"guest post"}]`, 201, `[{"id":4}]`},
+	{"create post to cascade", "POST", "/api/thread/1/create",
+		`[{"author":"dave","message":"dave root"}]`, 201, `[{"id":5}]`},
+	{"create reply to cascade", "POST", "/api/thread/1/create",
+		`[{"author":"carol","message":"reply","parent":5}]`, 201, `[{"id":6}]`},
+	{"vote to cascade", "POST", "/api/thread/1/vote", `{"nickname":"dave","voice":1}`, 200, `{"votes":-1}`},
+	{"login to cascade", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"delete user with unknown mode", "DELETE", "/api/user/dave/profile?mode=erase", "", 400, errorBody},
+	{"delete user with cascade", "DELETE", "/api/user/DAVE/profile?mode=cascade", "", 200,
+		`{"nickname":"dave","mode":"cascade","forums":1,"threads":1,"posts":3,"votes":1}`},
+	{"cascaded user is gone", "GET", "/api/user/dave/profile", "", 404, errorBody},
+	{"cascaded forum is gone", "GET", "/api/forum/dave-forum/details", "", 404, errorBody},
+	{"cascaded reply is gone", "GET", "/api/post/6/details", "", 404, errorBody},
+	{"cascade keeps counters", "GET", "/api/forum/e2e-forum/details", "", 200, `{"posts":3,"threads":2}`},
+	{"cascade subtracts votes", "GET", "/api/thread/1/details", "", 200, `{"votes":-2}`},
+	{"cascade drops idle participants", "GET", "/api/forum/e2e-forum/users", "", 200,
+		`[{"nickname":"alice"},{"nickname":"Bob"}]`},
+	{"logout after cascade", "POST", "/api/auth/logout", "", 200, ""},
+	{"create post to anonymize", "POST", "/api/thread/2/create",
+		`[{"author":"carol","message":"keep me"}]`, 201, `[{"id":7}]`},
+	{"vote to anonymize", "POST", "/api/thread/2/vote", `{"nickname":"carol","voice":1}`, 200, `{"votes":1}`},
+	{"login to anonymize", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"delete user", "DELETE", "/api/user/carol/profile", "", 200,
+		`{"nickname":"carol","mode":"anonymize","forums":0,"threads":0,"posts":1,"votes":1}`},
+	{"anonymized user is gone", "GET", "/api/user/carol/profile", "", 404, errorBody},
+	{"anonymized post stays", "GET", "/api/post/7/details", "", 200,
+		`{"post":{"author":"[deleted]","message":"keep me"}}`},
+	{"anonymize removes votes", "GET", "/api/thread/2/details", "", 200, `{"votes":0}`},
+	{"tombstone user exists", "GET", "/api/users?prefix=%5Bdel", "", 200, `[{"nickname":"[deleted]"}]`},
+	{"delete tombstone user", "DELETE", "/api/user/%5Bdeleted%5D/profile", "", 400, errorBody},
+	{"create tombstone user", "POST", "/api/user/%5BDELETED%5D/create",
+		`{"fullname":"Fake","email":"fake@example.com","about":""}`, 400, errorBody},
+	{"create user with tombstone email", "POST", "/api/user/mallory/create",
+		`{"fullname":"Mallory","email":"DELETED@invalid","about":""}`, 400, errorBody},
+	{"update user to tombstone email", "POST", "/api/user/alice/profile", `{"email":"deleted@invalid"}`, 400,
+		errorBody},
+	{"delete missing user", "DELETE", "/api/user/nobody/profile", "", 404, errorBody},
+	{"logout after deleting users", "POST", "/api/auth/logout", "", 200, ""},
+
+	// auth
+	{"create user with short password", "POST", "/api/user/grace/create",
+		`{"fullname":"Grace","email":"grace@example.com","about":"","password":"short"}`, 400, errorBody},
+	{"create user with password", "POST", "/api/user/grace/create",
+		`{"fullname":"Grace","email":"grace@example.com","about":"","password":"correct horse"}`, 201,
+		`{"nickname":"grace"}`},
+	{"password is not returned", "GET", "/api/user/grace/profile", "", 200, `{"nickname":"grace"}`},
+	{"login with wrong password", "POST", "/api/auth/login", `{"nickname":"grace","password":"wrong horse"}`, 401,
+		errorBody},
+	{"login without a password set", "POST", "/api/auth/login", `{"nickname":"alice","password":"anything"}`, 401,
+		errorBody},
+	{"login missing user", "POST", "/api/auth/login", `{"nickname":"nobody","password":"anything"}`, 401,
+		errorBody},
+	{"login", "POST", "/api/auth/login", `{"nickname":"GRACE","password":"correct horse"}`, 200,
+		`{"nickname":"grace","token":"*","expires":"*"}`},
+	{"authenticated request", "GET", "/api/user/grace/profile", "", 200, `{"nickname":"grace"}`},
+	{"post as session user", "POST", "/api/thread/1/create", `[{"message":"mine"}]`, 201,
+		`[{"id":8,"author":"grace"}]`},
+	{"post as another user", "POST", "/api/thread/1/create", `[{"author":"alice","message":"not mine"}]`, 403,
+		errorBody},
+	{"vote as session user", "POST", "/api/thread/1/vote", `{"voice":1}`, 200, `{"votes":-1}`},
+	{"vote as another user", "POST", "/api/thread/1/vote", `{"nickname":"Bob","voice":1}`, 403, errorBody},
+	{"edit own post", "POST", "/api/post/8/details", `{"message":"edited mine"}`, 200,
+		`{"author":"grace","message":"edited mine","isEdited":true}`},
+	{"edit post of another user", "POST", "/api/post/1/details", `{"message":"hijacked"}`, 403, errorBody},
+	{"create thread as session user", "POST", "/api/forum/e2e-forum/create", `{"title":"Grace","message":"hi"}`, 201,
+		`{"id":4,"author":"grace"}`},
+	{"edit own thread", "POST", "/api/thread/4/details", `{"message":"edited"}`, 200, `{"message":"edited"}`},
+	{"edit thread of another user", "POST", "/api/thread/1/details", `{"title":"hijacked"}`, 403, errorBody},
+	{"create forum as another user", "POST", "/api/forum/create", `{"slug":"stolen","title":"x","user":"alice"}`,
+		403, errorBody},
+	{"logout", "POST", "/api/auth/logout", "", 200, ""},
+	{"logout without session", "POST", "/api/auth/logout", "", 401, errorBody},
+	{"post as protected user without login", "POST", "/api/thread/1/create",
+		`[{"author":"alice","message":"fine"},{"author":"GRACE","message":"forged"}]`, 401, errorBody},
+	{"vote as protected user without login", "POST", "/api/thread/1/vote", `{"nickname":"grace","voice":-1}`, 401,
+		errorBody},
+	{"edit post of protected user without login", "POST", "/api/post/8/details", `{"message":"forged"}`, 401,
+		errorBody},
+	{"edit thread of protected user without login", "POST", "/api/thread/4/details", `{"title":"forged"}`, 401,
+		errorBody},
+	{"create forum as protected user without login", "POST", "/api/forum/create",
+		`{"slug":"forged","title":"x","user":"grace"}`, 401, errorBody},
+	{"update protected user without login", "POST", "/api/user/grace/profile", `{"about":"x"}`, 401, errorBody},
+	{"login again", "POST", "/api/auth/login", `{"nickname":"grace","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"create API token without scopes", "POST", "/api/auth/tokens", `{"name":"ci"}`, 400, errorBody},
+	{"create API token with unknown scope", "POST", "/api/auth/tokens", `{"name":"ci","scopes":["write"]}`, 400,
+		errorBody},
+	{"create API token", "POST", "/api/auth/tokens", `{"name":"ci","scopes":["post","post"]}`, 201,
+		`{"id":1,"nickname":"grace","name":"ci","scopes":["post"],"created":"*","lastUsed":null,"token":"*"}`},
+	{"post with API token", "POST", "/api/thread/1/create", `[{"message":"from ci"}]`, 201,
+		`[{"author":"grace"}]`},
+	{"read without read scope", "GET", "/api/thread/1/details", "", 403, errorBody},
+	{"list API tokens without read scope", "GET", "/api/auth/tokens", "", 403, errorBody},
+	{"create API token without admin scope", "POST", "/api/auth/tokens", `{"name":"more","scopes":["admin"]}`, 403,
+		errorBody},
+	{"revoke API token without admin scope", "DELETE", "/api/auth/tokens/99", "", 404, errorBody},
+	{"logout with API token", "POST", "/api/auth/logout", "", 401, errorBody},
+	{"login replaces API token", "POST", "/api/auth/login", `{"nickname":"grace","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"list API tokens", "GET", "/api/auth/tokens", "", 200,
+		`[{"id":1,"name":"ci","scopes":["post"],"lastUsed":"*"}]`},
+	{"create admin API token", "POST", "/api/auth/tokens", `{"name":"ops","scopes":["admin"]}`, 201,
+		`{"id":2,"token":"*"}`},
+	{"read with admin scope", "GET", "/api/thread/1/details", "", 200, `{"id":1}`},
+	{"revoke other API token", "DELETE", "/api/auth/tokens/1", "", 200, ""},
+	{"revoke missing API token", "DELETE", "/api/auth/tokens/1", "", 404, errorBody},
+	{"revoke own API token", "DELETE", "/api/auth/tokens/2", "", 200, ""},
+	{"revoked API token", "GET", "/api/thread/1/details", "", 401, errorBody},
+	{"login after revoking", "POST", "/api/auth/login", `{"nickname":"grace","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"list API tokens after revoking", "GET", "/api/auth/tokens", "", 200, `[]`},
+	{"delete logged in user", "DELETE", "/api/user/grace/profile?mode=cascade", "", 200,
+		`{"nickname":"grace","threads":1,"posts":2,"votes":1}`},
+	{"session of deleted user is gone", "GET", "/api/user/alice/profile", "", 401, errorBody},
+	{"logout after delete", "POST", "/api/auth/logout", "", 401, errorBody},
+	{"cascade restores votes", "GET", "/api/thread/1/details", "", 200, `{"votes":-2}`},
+
+	// roles
+	{"create member", "POST", "/api/user/henry/create",
+		`{"fullname":"Henry","email":"henry@example.com","about":"","password":"correct horse"}`, 201, ""},
+	{"login as member", "POST", "/api/auth/login", `{"nickname":"henry","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"service as member", "GET", "/api/service/status", "", 403, errorBody},
+	{"clear as member", "POST", "/api/service/clear", "", 403, errorBody},
+	{"set role as member", "POST", "/api/user/henry/role", `{"role":"admin"}`, 403, errorBody},
+	{"update another user", "POST", "/api/user/alice/profile", `{"about":"x"}`, 403, errorBody},
+	{"update another user through own profile", "POST", "/api/user/henry/profile",
+		`{"nickname":"alice","about":"hijacked"}`, 200, `{"nickname":"henry","about":"hijacked"}`},
+	{"other user keeps profile", "GET", "/api/user/alice/profile", "", 200, `{"nickname":"alice","about":"first"}`},
+	{"delete another user", "DELETE", "/api/user/alice/profile", "", 403, errorBody},
+	{"grant moderator of foreign forum", "POST", "/api/forum/e2e-forum/moderators", `{"nickname":"henry"}`, 403,
+		errorBody},
+	{"login as admin", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"service as admin", "GET", "/api/service/status", "", 200, `{"user":5}`},
+	{"grant moderator", "POST", "/api/forum/e2e-forum/moderators", `{"nickname":"HENRY"}`, 201,
+		`[{"nickname":"henry"}]`},
+	{"grant moderator twice", "POST", "/api/forum/e2e-forum/moderators", `{"nickname":"henry"}`, 409, errorBody},
+	{"grant moderator to missing user", "POST", "/api/forum/e2e-forum/moderators", `{"nickname":"nobody"}`, 404,
+		errorBody},
+	{"list moderators", "GET", "/api/forum/e2e-forum/moderators", "", 200, `[{"nickname":"henry"}]`},
+	{"list moderators of missing forum", "GET", "/api/forum/nowhere/moderators", "", 404, errorBody},
+	{"admin edits any post", "POST", "/api/post/1/details", `{"message":"moderated"}`, 200,
+		`{"author":"alice","message":"moderated"}`},
+	{"set unknown role", "POST", "/api/user/henry/role", `{"role":"owner"}`, 400, errorBody},
+	{"get role", "GET", "/api/user/henry/role", "", 200, `{"nickname":"henry","role":"member"}`},
+	{"login as moderator", "POST", "/api/auth/login", `{"nickname":"henry","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"moderator edits post", "POST", "/api/post/3/details", `{"message":"moderated again"}`, 200,
+		`{"message":"moderated again"}`},
+	{"moderator edits thread", "POST", "/api/thread/2/details", `{"title":"Moderated"}`, 200,
+		`{"title":"Moderated"}`},
+	{"moderator manages moderators", "DELETE", "/api/forum/e2e-forum/moderators/henry", "", 403, errorBody},
+	{"login as admin again", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"revoke moderator", "DELETE", "/api/forum/e2e-forum/moderators/HENRY", "", 200, `[]`},
+	{"revoke moderator twice", "DELETE", "/api/forum/e2e-forum/moderators/henry", "", 404, errorBody},
+	{"ban user", "POST", "/api/user/henry/role", `{"role":"banned"}`, 200, `{"nickname":"henry","role":"banned"}`},
+	{"login as banned user", "POST", "/api/auth/login", `{"nickname":"henry","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"banned user reads", "GET", "/api/thread/1/details", "", 200, `{"id":1}`},
+	{"banned user votes", "POST", "/api/thread/1/vote", `{"voice":1}`, 403, errorBody},
+	{"banned user logs out", "POST", "/api/auth/logout", "", 200, ""},
+	{"login to delete member", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"delete member", "DELETE", "/api/user/henry/profile", "", 200, `{"nickname":"henry"}`},
+	{"create user without password to ban", "POST", "/api/user/ivan/create",
+		`{"fullname":"Ivan","email":"ivan@example.com","about":""}`, 201, ""},
+	{"ban user without password", "POST", "/api/user/ivan/role", `{"role":"banned"}`, 200, `{"role":"banned"}`},
+	{"logout after banning", "POST", "/api/auth/logout", "", 200, ""},
+	{"post as banned user without login", "POST", "/api/thread/1/create", `[{"author":"ivan","message":"banned"}]`,
+		403, errorBody},
+	{"update banned user without login", "POST", "/api/user/ivan/profile", `{"about":"x"}`, 403, errorBody},
+	{"login to delete banned user", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`,
+		200, `{"token":"*"}`},
+	{"delete banned user", "DELETE", "/api/user/ivan/profile", "", 200, `{"nickname":"ivan"}`},
+	{"logout after deleting member", "POST", "/api/auth/logout", "", 200, ""},
+
+	// deleted posts
+	{"create post to delete", "POST", "/api/thread/1/create", `[{"author":"alice","message":"doomed"}]`, 201,
+		`[{"id":10}]`},
+	{"create reply to deleted post", "POST", "/api/thread/1/create",
+		`[{"author":"bob","message":"still here","parent":10}]`, 201, `[{"id":11,"parent":10}]`},
+	{"delete post", "DELETE", "/api/post/10/details", "", 200,
+		`{"id":10,"author":"alice","isDeleted":true,"message":""}`},
+	{"deleted post is a tombstone", "GET", "/api/post/10/details", "", 200,
+		`{"post":{"id":10,"isDeleted":true,"message":""}}`},
+	{"tombstone keeps its reply in place", "GET", "/api/thread/1/posts?sort=flat&desc=true&limit=2", "", 200,
+		`[{"id":11,"parent":10},{"id":10,"isDeleted":true,"message":""}]`},
+	{"forum posts skip deleted", "GET", "/api/forum/e2e-forum/details", "", 200, `{"posts":5}`},
+	{"search skips deleted", "GET", "/api/search?q=doomed", "", 200, `[]`},
+	{"edit deleted post", "POST", "/api/post/10/details", `{"message":"back"}`, 409, errorBody},
+	{"delete post twice", "DELETE", "/api/post/10/details", "", 409, errorBody},
+	{"delete missing post", "DELETE", "/api/post/999/details", "", 404, errorBody},
+	{"purge without login", "POST", "/api/service/purge", "", 401, errorBody},
+	{"login to purge", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"purge keeps tombstone with replies", "POST", "/api/service/purge", "", 200, `{"posts":0}`},
+	{"delete reply", "DELETE", "/api/post/11/details", "", 200, `{"id":11,"isDeleted":true}`},
+	{"purge", "POST", "/api/service/purge", "", 200, `{"posts":2}`},
+	{"purged post is gone", "GET", "/api/post/10/details", "", 404, errorBody},
+	{"logout after purge", "POST", "/api/auth/logout", "", 200, ""},
+
+	// service
+	{"login for service", "POST", "/api/auth/login", `{"nickname":"root","password":"correct horse"}`, 200,
+		`{"token":"*"}`},
+	{"status", "GET", "/api/service/status", "", 200, `{"forum":1,"thread":2,"post":4,"user":4}`},
+	{"clear", "POST", "/api/service/clear", "", 200, ""},
+	{"clear ends sessions", "GET", "/api/service/status", "", 401, errorBody},
+	{"logout after clear", "POST", "/api/auth/logout", "", 401, errorBody},
+	{"users are gone after clear", "GET", "/api/users", "", 200, `[]`},
+}
@@ -0,0 +1,174 @@
+package server_test
+
+import (
+	"DbProjectForum/configs"
+	_server "DbProjectForum/internal/app/server"
+	"DbProjectForum/internal/pkg/testdb"
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"net"
+	"sort"
+	"testing"
+)
+
+// step is one request together with the expected response. want holds JSON
+// that the response body must contain: objects may have extra fields, arrays
+// must match element by element and "*" matches any non-null value. An empty
+// want skips the body check.
+//
+// The steps share one session like a browser would: the token of a
+// successful login or of a newly created API token is sent with every
+// following request until a logout. Logins are sent without a token, so a
+// narrowly scoped API token can't lock the steps out.
+type step struct {
+	name   string
+	method string
+	path   string
+	body   string
+	status int
+	want   string
+}
+
+// TestEndToEnd drives every API route through the full handler stack over an
+// in-memory listener and checks status codes and response bodies. It runs on
+// each backend in testdb.Backends; Postgres only runs when FORUM_TEST_POSTGRES
+// allows the suite to clear the configured database.
+func TestEndToEnd(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	for _, backend := range testdb.Backends {
+		backend := backend
+		t.Run(backend.Name, func(t *testing.T) {
+			config := configs.Default()
+			config.Auth.Admins = []string{admin}
+
+			ln := fasthttputil.NewInmemoryListener()
+			defer ln.Close()
+			server := &fasthttp.Server{
+				Handler:            _server.NewHandler(backend.Open(t), config),
+				MaxRequestBodySize: config.Server.MaxBodySize,
+			}
+			go server.Serve(ln)
+
+			client := &fasthttp.Client{
+				Dial: func(addr string) (net.Conn, error) {
+					return ln.Dial()
+				},
+			}
+
+			session := ""
+			for _, s := range steps {
+				if err := run(client, s, &session); err != nil {
+					t.Errorf("%s: %s %s: %s", s.name, s.method, s.path, err)
+				}
+			}
+		})
+	}
+}
+
+func run(client *fasthttp.Client, s step, session *string) error {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod(s.method)
+	req.SetRequestURI("http://e2e" + s.path)
+	if s.body != "" {
+		req.Header.SetContentType("application/json")
+		req.SetBodyString(s.body)
+	}
+	if *session != "" && s.path != "/api/auth/login" {
+		req.Header.Set(fasthttp.HeaderAuthorization, "Bearer "+*session)
+	}
+
+	if err := client.Do(req, resp); err != nil {
+		return err
+	}
+	trackSession(s, resp, session)
+
+	if resp.StatusCode() != s.status {
+		return fmt.Errorf("status %d, want %d, body %s", resp.StatusCode(), s.status, resp.Body())
+	}
+	if s.want == "" {
+		return nil
+	}
+
+	var want, got interface{}
+	if err := json.Unmarshal([]byte(s.want), &want); err != nil {
+		return fmt.Errorf("bad expectation: %w", err)
+	}
+	if err := json.Unmarshal(resp.Body(), &got); err != nil {
+		return fmt.Errorf("response is not JSON: %w, body %s", err, resp.Body())
+	}
+	if mismatch := contains(want, got, "$"); mismatch != "" {
+		return fmt.Errorf("%s, body %s", mismatch, resp.Body())
+	}
+	return nil
+}
+
+func trackSession(s step, resp *fasthttp.Response, session *string) {
+	switch {
+	case s.path == "/api/auth/login" || s.method == "POST" && s.path == "/api/auth/tokens":
+		var issued struct {
+			Token string `json:"token"`
+		}
+		if resp.StatusCode()/100 == 2 && json.Unmarshal(resp.Body(), &issued) == nil {
+			*session = issued.Token
+		}
+	case s.path == "/api/auth/logout":
+		*session = ""
+	}
+}
+
+// contains reports the first place where got does not contain want, or an
+// empty string when it does.
+func contains(want, got interface{}, path string) string {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		gotMap, ok := got.(map[string]interface{})
+		if !ok {
+			return fmt.Sprintf("%s is %v, want an object", path, got)
+		}
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			gotValue, ok := gotMap[key]
+			if !ok {
+				return fmt.Sprintf("%s.%s is missing", path, key)
+			}
+			if mismatch := contains(want[key], gotValue, path+"."+key); mismatch != "" {
+				return mismatch
+			}
+		}
+		return ""
+	case []interface{}:
+		gotSlice, ok := got.([]interface{})
+		if !ok {
+			return fmt.Sprintf("%s is %v, want an array", path, got)
+		}
+		if len(gotSlice) != len(want) {
+			return fmt.Sprintf("%s has %d elements, want %d", path, len(gotSlice), len(want))
+		}
+		for i := range want {
+			if mismatch := contains(want[i], gotSlice[i], fmt.Sprintf("%s[%d]", path, i)); mismatch != "" {
+				return mismatch
+			}
+		}
+		return ""
+	default:
+		if want == "*" && got != nil {
+			return ""
+		}
+		if want != got {
+			return fmt.Sprintf("%s is %v, want %v", path, got, want)
+		}
+		return ""
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"DbProjectForum/configs"
+	_authHandlers "DbProjectForum/internal/app/auth/delivery"
+	_authRepo "DbProjectForum/internal/app/auth/repository"
+	_forumHandlers "DbProjectForum/internal/app/forum/delivery"
+	_forumRepo "DbProjectForum/internal/app/forum/repository"
+	_healthHandlers "DbProjectForum/internal/app/health/delivery"
+	"DbProjectForum/internal/app/storage"
+	_userHandlers "DbProjectForum/internal/app/user/delivery"
+	_userRepo "DbProjectForum/internal/app/user/repository"
+	"DbProjectForum/internal/pkg/metrics"
+	"DbProjectForum/internal/pkg/middleware"
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+)
+
+// NewHandler registers every route on top of repos, wrapped in the logging and
+// metrics decorators, and returns the router behind the middleware chain.
+func NewHandler(repos storage.Repositories, config configs.Config) fasthttp.RequestHandler {
+	r := router.New()
+	r.SaveMatchedRoutePath = true
+
+	slowQuery := config.Log.SlowQuery
+	userRepo := _userRepo.NewMetricsUserRepository(_userRepo.NewLoggingUserRepository(repos.User, slowQuery))
+	forumRepo := _forumRepo.NewMetricsForumRepository(_forumRepo.NewLoggingForumRepository(repos.Forum, slowQuery))
+	authRepo := _authRepo.NewMetricsAuthRepository(_authRepo.NewLoggingAuthRepository(repos.Auth, slowQuery))
+
+	_userHandlers.NewUserHandler(r, userRepo, forumRepo, config.Auth.Required)
+	_forumHandlers.NewForumHandler(r, forumRepo, userRepo, config.Auth.Required)
+	_authHandlers.NewAuthHandler(r, authRepo, userRepo, config.Auth.SessionTTL)
+	_healthHandlers.NewHealthHandler(r, repos.Pool)
+	r.GET("/metrics", metrics.Handler())
+
+	return middleware.Chain(r.Handler,
+		middleware.RequestID,
+		middleware.Logging,
+		middleware.Metrics,
+		middleware.Recover,
+		middleware.ApplicationJSON,
+		_authHandlers.Authenticate(authRepo, config.Auth.Admins),
+	)
+}
@@ -0,0 +1,419 @@
+package storage_test
+
+import (
+	"DbProjectForum/internal/app/forum/models"
+	"DbProjectForum/internal/app/storage"
+	userModels "DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/testdb"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// The conformance suite runs every test against each backend in
+// testdb.Backends, so the memory storage keeps behaving like the Postgres
+// schema. Postgres runs only with FORUM_TEST_POSTGRES set; see testdb.
+
+var ctx = context.Background()
+
+func forEachBackend(t *testing.T, test func(t *testing.T, repos storage.Repositories)) {
+	for _, backend := range testdb.Backends {
+		backend := backend
+		t.Run(backend.Name, func(t *testing.T) {
+			test(t, backend.Open(t))
+		})
+	}
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkKind(t *testing.T, err, kind error) {
+	t.Helper()
+	if !errors.Is(err, kind) {
+		t.Errorf("error = %v, want %v", err, kind)
+	}
+}
+
+func checkEqual(t *testing.T, what string, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %#v, want %#v", what, got, want)
+	}
+}
+
+func addUser(t *testing.T, repos storage.Repositories, nickname string) {
+	t.Helper()
+	check(t, repos.User.Add(ctx, userModels.User{
+		Nickname: nickname,
+		FullName: "Full " + nickname,
+		Email:    nickname + "@example.com",
+		About:    "about " + nickname,
+	}))
+}
+
+func nicknames(users []userModels.User) []string {
+	result := make([]string, 0, len(users))
+	for _, userObj := range users {
+		result = append(result, userObj.Nickname)
+	}
+	return result
+}
+
+func postIDs(posts []models.Post) []int64 {
+	result := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		result = append(result, post.Id)
+	}
+	return result
+}
+
+func threadIDs(threads []models.Thread) []int32 {
+	result := make([]int32, 0, len(threads))
+	for _, thread := range threads {
+		result = append(result, thread.Id)
+	}
+	return result
+}
+
+func parent(id int64) models.JsonNullInt64 {
+	var result models.JsonNullInt64
+	result.Int64, result.Valid = id, true
+	return result
+}
+
+func TestUsers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos storage.Repositories) {
+		addUser(t, repos, "alice")
+		addUser(t, repos, "bob")
+
+		userObj, err := repos.User.GetByNick(ctx, "ALICE")
+		check(t, err)
+		checkEqual(t, "user", []string{userObj.Nickname, userObj.FullName, userObj.Email, userObj.About},
+			[]string{"alice", "Full alice", "alice@example.com", "about alice"})
+
+		_, err = repos.User.GetByNick(ctx, "nobody")
+		checkKind(t, err, apperrors.ErrNotFound)
+
+		err = repos.User.Add(ctx, userModels.User{Nickname: "Alice", FullName: "Other", Email: "other@example.com"})
+		checkKind(t, err, apperrors.ErrConflict)
+		err = repos.User.Add(ctx, userModels.User{Nickname: "carol", FullName: "Carol", Email: "BOB@example.com"})
+		checkKind(t, err, apperrors.ErrConflict)
+
+		conflicting, err := repos.User.GetByNickAndEmail(ctx, "ALICE", "bob@example.com")
+		check(t, err)
+		got := nicknames(conflicting)
+		sort.Strings(got)
+		checkEqual(t, "conflicting users", got, []string{"alice", "bob"})
+
+		updated, err := repos.User.Update(ctx, userModels.User{Nickname: "alice", FullName: "Alice A"})
+		check(t, err)
+		checkEqual(t, "updated user", []string{updated.FullName, updated.Email, updated.About},
+			[]string{"Alice A", "alice@example.com", "about alice"})
+
+		_, err = repos.User.Update(ctx, userModels.User{Nickname: "alice", Email: "Bob@example.com"})
+		checkKind(t, err, apperrors.ErrConflict)
+		_, err = repos.User.Update(ctx, userModels.User{Nickname: "nobody", About: "x"})
+		checkKind(t, err, apperrors.ErrNotFound)
+
+		// NOT NULL lets an empty fullname through.
+		check(t, repos.User.Add(ctx, userModels.User{Nickname: "dave", Email: "dave@example.com"}))
+	})
+}
+
+func TestForumsAndThreads(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos storage.Repositories) {
+		addUser(t, repos, "alice")
+
+		_, err := repos.Forum.Add(ctx, models.Forum{Slug: "lost", Title: "Lost", User: "nobody"})
+		checkKind(t, err, apperrors.ErrNotFound)
+
+		forumObj, err := repos.Forum.Add(ctx, models.Forum{Slug: "Talk", Title: "Talk", User: "ALICE"})
+		check(t, err)
+		checkEqual(t, "forum owner", forumObj.User, "alice")
+
+		_, err = repos.Forum.Add(ctx, models.Forum{Slug: "TALK", Title: "Again", User: "alice"})
+		checkKind(t, err, apperrors.ErrConflict)
+
+		forumObj, err = repos.Forum.GetBySlug(ctx, "talk")
+		check(t, err)
+		checkEqual(t, "forum slug", forumObj.Slug, "Talk")
+		_, err = repos.Forum.GetBySlug(ctx, "missing")
+		checkKind(t, err, apperrors.ErrNotFound)
+
+		older := models.Thread{Author: "alice", Forum: "talk", Title: "Older", Message: "m",
+			Created: "2020-01-01T00:00:00.000Z"}
+		older.Slug.String, older.Slug.Valid = "older", true
+		older, err = repos.Forum.AddThread(ctx, older)
+		check(t, err)
+		checkEqual(t, "thread forum", older.Forum, "Talk")
+		checkEqual(t, "thread created", older.Created, "2020-01-01T00:00:00.000Z")
+
+		newer, err := repos.Forum.AddThread(ctx, models.Thread{Author: "alice", Forum: "Talk", Title: "Newer",
+			Message: "m", Created: "2021-01-01T00:00:00.000Z"})
+		check(t, err)
+		checkEqual(t, "slugless thread slug", newer.Slug.Valid, false)
+
+		duplicate := models.Thread{Author: "alice", Forum: "talk", Title: "Again", Message: "m"}
+		duplicate.Slug.String, duplicate.Slug.Valid = "OLDER", true
+		_, err = repos.Forum.AddThread(ctx, duplicate)
+		checkKind(t, err, apperrors.ErrConflict)
+		_, err = repos.Forum.AddThread(ctx, models.Thread{Author: "alice", Forum: "missing", Title: "x", Message: "m"})
+		checkKind(t, err, apperrors.ErrNotFound)
+		_, err = repos.Forum.AddThread(ctx, models.Thread{Author: "nobody", Forum: "talk", Title: "x", Message: "m"})
+		checkKind(t, err, apperrors.ErrNotFound)
+
+		thread, err := repos.Forum.GetThreadBySlug(ctx, "OLDER")
+		check(t, err)
+		checkEqual(t, "thread by slug", thread.Id, older.Id)
+		thread, err = repos.Forum.GetThreadByID(ctx, int(newer.Id))
+		check(t, err)
+		checkEqual(t, "thread by id", thread.Title, "Newer")
+		_, err = repos.Forum.GetThreadByID(ctx, 999)
+		checkKind(t, err, apperrors.ErrNotFound)
+
+		threads, err := repos.Forum.GetThreads(ctx, "TALK", 0, "", false)
+		check(t, err)
+		checkEqual(t, "threads", threadIDs(threads), []int32{older.Id, newer.Id})
+		threads, err = repos.Forum.GetThreads(ctx, "talk", 1, "", true)
+		check(t, err)
+		checkEqual(t, "threads desc", threadIDs(threads), []int32{newer.Id})
+		threads, err = repos.Forum.GetThreads(ctx, "talk", 0, "2020-06-01T00:00:00.000Z", true)
+		check(t, err)
+		checkEqual(t, "threads desc since", threadIDs(threads), []int32{older.Id})
+		threads, err = repos.Forum.GetThreads(ctx, "talk", 0, "2021-01-01T00:00:00.000Z", false)
+		check(t, err)
+		checkEqual(t, "threads since", threadIDs(threads), []int32{newer.Id})
+
+		forumObj, err = repos.Forum.GetBySlug(ctx, "talk")
+		check(t, err)
+		checkEqual(t, "forum threads", forumObj.Threads, int32(2))
+	})
+}
+
+// postTree creates a thread with the posts
+//
+//	1
+//	└ 3
+//	  └ 4
+//	2
+//
+// in three batches, so that parents exist before their replies.
+func postTree(t *testing.T, repos storage.Repositories) models.Thread {
+	t.Helper()
+	addUser(t, repos, "alice")
+	addUser(t, repos, "bob")
+	_, err := repos.Forum.Add(ctx, models.Forum{Slug: "talk", Title: "Talk", User: "alice"})
+	check(t, err)
+	thread, err := repos.Forum.AddThread(ctx, models.Thread{Author: "alice", Forum: "talk", Title: "T", Message: "m"})
+	check(t, err)
+
+	for _, batch := range [][]models.Post{
+		{{Author: "bob", Message: "first"}, {Author: "bob", Message: "second"}},
+		{{Author: "bob", Message: "reply", Parent: parent(1)}},
+		{{Author: "bob", Message: "reply to reply", Parent: parent(3)}},
+	} {
+		_, err := repos.Forum.AddPosts(ctx, batch, int(thread.Id))
+		check(t, err)
+	}
+	return thread
+}
+
+func TestPosts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos storage.Repositories) {
+		thread := postTree(t, repos)
+
+		post, err := repos.Forum.GetPost(ctx, 4, nil)
+		check(t, err)
+		postObj := post["post"].(models.Post)
+		checkEqual(t, "post", []interface{}{postObj.Author, postObj.Message, postObj.Forum, postObj.Thread,
+			postObj.Parent.Int64}, []interface{}{"bob", "reply to reply", "talk", thread.Id, int64(3)})
+		var path []int64
+		check(t, postObj.Path.AssignTo(&path))
+		checkEqual(t, "post path", path, []int64{1, 3, 4})
+
+		root, err := repos.Forum.GetPost(ctx, 1, nil)
+		check(t, err)
+		checkEqual(t, "root parent", root["post"].(models.Post).Parent.Valid, false)
+		_, err = repos.Forum.GetPost(ctx, 999, nil)
+		checkKind(t, err, apperrors.ErrNotFound)
+
+		for _, test := range []struct {
+			sort  string
+			limit int
+			since int
+			desc  bool
+			want  []int64
+		}{
+			{"flat", 0, 0, false, []int64{1, 2, 3, 4}},
+			{"flat", 2, 0, true, []int64{4, 3}},
+			{"flat", 0, 2, false, []int64{3, 4}},
+			{"tree", 0, 0, false, []int64{1, 3, 4, 2}},
+			{"tree", 0, 0, true, []int64{2, 4, 3, 1}},
+			{"tree", 0, 3, false, []int64{4, 2}},
+			{"tree", 2, 4, true, []int64{3, 1}},
+			{"parent_tree", 1, 0, false, []int64{1, 3, 4}},
+			{"parent_tree", 0, 0, true, []int64{2, 1, 3, 4}},
+			{"parent_tree", 0, 1, false, []int64{2}},
+		} {
+			posts, err := repos.Forum.GetPosts(ctx, models.Thread{Id: thread.Id}, test.limit, test.since,
+				test.sort, test.desc)
+			check(t, err)
+			checkEqual(t, test.sort+" posts", postIDs(posts), test.want)
+		}
+
+		_, err = repos.Forum.GetPosts(ctx, models.Thread{Id: thread.Id}, 0, 0, "sideways", false)
+		checkKind(t, err, apperrors.ErrValidation)
+
+		edited, err := repos.Forum.UpdatePost(ctx, models.Post{Id: 2, Message: "changed"})
+		check(t, err)
+		checkEqual(t, "edited post", []interface{}{edited.Message, edited.IsEdited}, []interface{}{"changed", true})
+		same, err := repos.Forum.UpdatePost(ctx, models.Post{Id: 1, Message: "first"})
+		check(t, err)
+		checkEqual(t, "post with the same message is edited", same.IsEdited, false)
+
+		forumObj, err := repos.Forum.GetBySlug(ctx, "talk")
+		check(t, err)
+		checkEqual(t, "forum posts", forumObj.Posts, int64(4))
+	})
+}
+
+func TestPostErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos storage.Repositories) {
+		thread := postTree(t, repos)
+		other, err := repos.Forum.AddThread(ctx, models.Thread{Author: "alice", Forum: "talk", Title: "O", Message: "m"})
+		check(t, err)
+
+		_, err = repos.Forum.AddPosts(ctx, []models.Post{{Author: "bob", Message: "x", Parent: parent(1)}},
+			int(other.Id))
+		checkKind(t, err, apperrors.ErrParentInOtherThread)
+		_, err = repos.Forum.AddPosts(ctx, []models.Post{{Author: "bob", Message: "x", Parent: parent(999)}},
+			int(thread.Id))
+		checkKind(t, err, apperrors.ErrParentInOtherThread)
+		_, err = repos.Forum.AddPosts(ctx, []models.Post{{Author: "bob", Message: "x"},
+			{Author: "nobody", Message: "x"}}, int(thread.Id))
+		checkKind(t, err, apperrors.ErrNotFound)
+		_, err = repos.Forum.AddPosts(ctx, []models.Post{{Author: "bob", Message: "x"}}, 999)
+		checkKind(t, err, apperrors.ErrNotFound)
+
+		posts, err := repos.Forum.AddPosts(ctx, nil, int(thread.Id))
+		check(t, err)
+		checkEqual(t, "empty batch", len(posts), 0)
+
+		// Failed batches add nothing.
+		forumObj, err := repos.Forum.GetBySlug(ctx, "talk")
+		check(t, err)
+		checkEqual(t, "forum posts", forumObj.Posts, int64(4))
+	})
+}
+
+func TestDeletedPosts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos storage.Repositories) {
+		postTree(t, repos)
+
+		deleted, err := repos.Forum.DeletePost(ctx, 3)
+		check(t, err)
+		checkEqual(t, "deleted post", []interface{}{deleted.Id, deleted.IsDeleted, deleted.Message},
+			[]interface{}{int64(3), true, ""})
+		_, err = repos.Forum.DeletePost(ctx, 3)
+		checkKind(t, err, apperrors.ErrConflict)
+		_, err = repos.Forum.DeletePost(ctx, 999)
+		checkKind(t, err, apperrors.ErrNotFound)
+
+		forumObj, err := repos.Forum.GetBySlug(ctx, "talk")
+		check(t, err)
+		checkEqual(t, "forum posts", forumObj.Posts, int64(3))
+
+		// 3 keeps its place while 4 is alive below it.
+		purged, err := repos.Forum.PurgeDeletedPosts(ctx)
+		check(t, err)
+		checkEqual(t, "purged with a live reply", purged, int64(0))
+
+		// Only live posts below a tombstone keep it, not live ones above like 1.
+		for _, id := range []int{4, 2} {
+			_, err := repos.Forum.DeletePost(ctx, id)
+			check(t, err)
+		}
+		purged, err = repos.Forum.PurgeDeletedPosts(ctx)
+		check(t, err)
+		checkEqual(t, "purged", purged, int64(3))
+
+		for _, id := range []int{2, 3, 4} {
+			_, err := repos.Forum.GetPost(ctx, id, nil)
+			checkKind(t, err, apperrors.ErrNotFound)
+		}
+		_, err = repos.Forum.GetPost(ctx, 1, nil)
+		check(t, err)
+	})
+}
+
+func TestVotes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos storage.Repositories) {
+		thread := postTree(t, repos)
+		id := int64(thread.Id)
+
+		check(t, repos.Forum.AddVote(ctx, models.Vote{Nickname: "alice", Voice: 1, IdThread: id}))
+		check(t, repos.Forum.AddVote(ctx, models.Vote{Nickname: "bob", Voice: -1, IdThread: id}))
+		checkKind(t, repos.Forum.AddVote(ctx, models.Vote{Nickname: "alice", Voice: 1, IdThread: id}),
+			apperrors.ErrConflict)
+		checkKind(t, repos.Forum.AddVote(ctx, models.Vote{Nickname: "nobody", Voice: 1, IdThread: id}),
+			apperrors.ErrNotFound)
+
+		check(t, repos.Forum.UpdateVote(ctx, models.Vote{Nickname: "ALICE", Voice: -1, IdThread: id}))
+		check(t, repos.Forum.UpdateVote(ctx, models.Vote{Nickname: "bob", Voice: -1, IdThread: id}))
+
+		thread, err := repos.Forum.GetThreadByID(ctx, int(id))
+		check(t, err)
+		checkEqual(t, "votes", thread.Votes, int32(-2))
+	})
+}
+
+func TestForumUsers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos storage.Repositories) {
+		postTree(t, repos)
+		addUser(t, repos, "carol")
+
+		for _, test := range []struct {
+			limit int
+			since string
+			desc  bool
+			want  []string
+		}{
+			{0, "", false, []string{"alice", "bob"}},
+			{0, "", true, []string{"bob", "alice"}},
+			{1, "", false, []string{"alice"}},
+			{0, "alice", false, []string{"bob"}},
+			{0, "bob", true, []string{"alice"}},
+		} {
+			users, err := repos.User.GetUsersByForum(ctx, "TALK", test.limit, test.since, test.desc)
+			check(t, err)
+			checkEqual(t, "forum users", nicknames(users), test.want)
+		}
+	})
+}
+
+func TestServiceStatus(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos storage.Repositories) {
+		postTree(t, repos)
+
+		status, err := repos.Forum.GetServiceStatus(ctx)
+		check(t, err)
+		checkEqual(t, "status", status, map[string]int{"forum": 1, "thread": 1, "post": 4, "user": 2})
+
+		check(t, repos.Forum.ClearDatabase(ctx))
+		status, err = repos.Forum.GetServiceStatus(ctx)
+		check(t, err)
+		checkEqual(t, "status after clear", status, map[string]int{"forum": 0, "thread": 0, "post": 0, "user": 0})
+
+		// Clearing restarts the ids.
+		thread := postTree(t, repos)
+		checkEqual(t, "thread id after clear", thread.Id, int32(1))
+	})
+}
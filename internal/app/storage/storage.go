@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"DbProjectForum/configs"
+	"DbProjectForum/internal/app/auth"
+	_authRepo "DbProjectForum/internal/app/auth/repository"
+	"DbProjectForum/internal/app/forum"
+	_forumRepo "DbProjectForum/internal/app/forum/repository"
+	"DbProjectForum/internal/app/migrations"
+	"DbProjectForum/internal/app/user"
+	_userRepo "DbProjectForum/internal/app/user/repository"
+	"DbProjectForum/internal/pkg/memstore"
+	"github.com/jackc/pgx"
+	"github.com/rs/zerolog/log"
+)
+
+// Repositories is the backend selected by the storage setting.
+type Repositories struct {
+	User  user.Repository
+	Forum forum.Repository
+	Auth  auth.Repository
+	// Pool is nil when the repositories keep their data in memory.
+	Pool *pgx.ConnPool
+}
+
+// Open creates the repositories for config.Storage without any decorators.
+func Open(config configs.Config) (Repositories, error) {
+	if config.Storage == configs.StorageMemory {
+		store := memstore.New()
+		return Repositories{
+			User:  _userRepo.NewMemoryUserRepository(store),
+			Forum: _forumRepo.NewMemoryForumRepository(store),
+			Auth:  _authRepo.NewMemoryAuthRepository(store),
+		}, nil
+	}
+
+	if config.Postgres.Migrate {
+		if err := migrate(config.Postgres); err != nil {
+			return Repositories{}, err
+		}
+	}
+
+	pool, err := OpenPool(config.Postgres)
+	if err != nil {
+		return Repositories{}, err
+	}
+
+	prepared := !config.Postgres.SimpleProtocol
+	userRepo := _userRepo.NewPostgresCafeRepository(pool, prepared)
+	return Repositories{
+		User:  userRepo,
+		Forum: _forumRepo.NewPostgresForumRepository(pool, userRepo, prepared, config.Postgres.CopyThreshold),
+		Auth:  _authRepo.NewPostgresAuthRepository(pool, prepared),
+		Pool:  pool,
+	}, nil
+}
+
+func (r Repositories) Close() {
+	if r.Pool != nil {
+		r.Pool.Close()
+	}
+}
+
+func connConfig(config configs.PostgresConfig) (pgx.ConnConfig, error) {
+	pgxConn, err := pgx.ParseConnectionString(config.ConnString())
+	if err != nil {
+		return pgx.ConnConfig{}, err
+	}
+	pgxConn.PreferSimpleProtocol = config.SimpleProtocol
+	return pgxConn, nil
+}
+
+// Connect opens a single connection without prepared statements, which works
+// before the schema they refer to exists.
+func Connect(config configs.PostgresConfig) (*pgx.Conn, error) {
+	pgxConn, err := connConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.Connect(pgxConn)
+}
+
+func migrate(config configs.PostgresConfig) error {
+	conn, err := Connect(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	applied, err := migrations.Up(conn)
+	for _, migration := range applied {
+		log.Info().Msgf("applied migration %s", migration)
+	}
+	return err
+}
+
+// OpenPool connects to Postgres, preparing the repository statements on every
+// new connection unless the simple protocol is configured.
+func OpenPool(config configs.PostgresConfig) (*pgx.ConnPool, error) {
+	pgxConn, err := connConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig := pgx.ConnPoolConfig{
+		ConnConfig:     pgxConn,
+		MaxConnections: config.MaxConnections,
+		AfterConnect:   nil,
+		AcquireTimeout: config.AcquireTimeout,
+	}
+	if !config.SimpleProtocol {
+		poolConfig.AfterConnect = prepareStatements
+	}
+
+	return pgx.NewConnPool(poolConfig)
+}
+
+func prepareStatements(conn *pgx.Conn) error {
+	if err := _userRepo.PrepareStatements(conn); err != nil {
+		return err
+	}
+	if err := _authRepo.PrepareStatements(conn); err != nil {
+		return err
+	}
+	return _forumRepo.PrepareStatements(conn)
+}
@@ -1,34 +1,53 @@
 package delivery
 
 import (
+	"DbProjectForum/internal/app/auth"
 	"DbProjectForum/internal/app/forum"
 	"DbProjectForum/internal/app/user"
 	"DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/identity"
+	"DbProjectForum/internal/pkg/requestid"
 	"DbProjectForum/internal/pkg/responses"
+	"bufio"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/fasthttp/router"
-	"github.com/jackc/pgx"
 	"github.com/valyala/fasthttp"
+	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 type userHandler struct {
-	userRepo  user.Repository
-	forumRepo forum.Repository
+	userRepo    user.Repository
+	forumRepo   forum.Repository
+	requireAuth bool
 }
 
-func NewUserHandler(r *router.Router, ur user.Repository, fr forum.Repository) {
+// NewUserHandler registers the user routes. Deleting, renaming and exporting
+// an account always needs a session, see checkOwner; with requireAuth,
+// changing one does too, see checkSelf.
+func NewUserHandler(r *router.Router, ur user.Repository, fr forum.Repository, requireAuth bool) {
 	handler := userHandler{
-		userRepo:  ur,
-		forumRepo: fr,
+		userRepo:    ur,
+		forumRepo:   fr,
+		requireAuth: requireAuth,
 	}
 
 	r.POST("/api/user/{nickname}/create", handler.Add)
 	r.GET("/api/user/{nickname}/profile", handler.Get)
 	r.POST("/api/user/{nickname}/profile", handler.Update)
+	r.DELETE("/api/user/{nickname}/profile", handler.Delete)
+	r.POST("/api/user/{nickname}/rename", handler.Rename)
+	r.GET("/api/user/{nickname}/export", handler.Export)
+	r.GET("/api/user/{nickname}/role", handler.GetRole)
+	r.POST("/api/user/{nickname}/role", handler.SetRole)
 
 	r.GET("/api/forum/{slug}/users", handler.GetByForum)
+	r.GET("/api/users", handler.GetUsers)
 }
 
 func (ur *userHandler) Add(ctx *fasthttp.RequestCtx) {
@@ -38,24 +57,54 @@ func (ur *userHandler) Add(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	newUser := models.User{Nickname: nickname}
-
-	err := json.Unmarshal(ctx.PostBody(), &newUser)
+	// The password is optional: accounts without one work as before but
+	// can't log in.
+	var registration struct {
+		models.User
+		Password string `json:"password"`
+	}
+	err := json.Unmarshal(ctx.PostBody(), &registration)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid user: %s", err), ctx)
+		return
+	}
+	newUser := registration.User
+	newUser.Nickname = nickname
+	if isTombstone(newUser.Nickname) {
+		responses.SendError(apperrors.Validation("Nickname %s is reserved", newUser.Nickname), ctx)
+		return
+	}
+	if isTombstoneEmail(newUser.Email) {
+		responses.SendError(apperrors.Validation("Email %s is reserved", newUser.Email), ctx)
 		return
 	}
 
-	err = ur.userRepo.Add(newUser)
+	if registration.Password != "" {
+		if len(registration.Password) < auth.MinPasswordLength || len(registration.Password) > auth.MaxPasswordLength {
+			responses.SendError(apperrors.Validation("Password must be %d to %d bytes long",
+				auth.MinPasswordLength, auth.MaxPasswordLength), ctx)
+			return
+		}
+		if newUser.PasswordHash, err = auth.HashPassword(registration.Password); err != nil {
+			responses.SendServerError(err.Error(), ctx)
+			return
+		}
+	}
 
-	if err != nil {
-		users, err := ur.userRepo.GetByNickAndEmail(newUser.Nickname, newUser.Email)
+	err = ur.userRepo.Add(ctx, newUser)
+	if errors.Is(err, apperrors.ErrConflict) {
+		users, err := ur.userRepo.GetByNickAndEmail(ctx, newUser.Nickname, newUser.Email)
 		if err != nil {
-			responses.SendServerError(err.Error(), ctx)
+			responses.SendError(err, ctx)
+			return
 		}
 		responses.SendResponse(409, users, ctx)
 		return
 	}
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
 
 	responses.SendResponse(201, newUser, ctx)
 	return
@@ -68,12 +117,17 @@ func (ur *userHandler) Get(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	userObj, err := ur.userRepo.GetByNick(nickname)
-	if err != nil {
-		err := responses.HttpError{
-			Message: fmt.Sprintf("Can't find user by nickname: %s", nickname),
+	userObj, err := ur.userRepo.GetByNick(ctx, nickname)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		// Former nicknames of renamed users redirect to the current profile.
+		if renamed, aliasErr := ur.userRepo.GetByAlias(ctx, nickname); aliasErr == nil {
+			ctx.Response.Header.Set("Location", "/api/user/"+url.PathEscape(renamed.Nickname)+"/profile")
+			responses.SendResponse(http.StatusMovedPermanently, renamed, ctx)
+			return
 		}
-		responses.SendResponse(404, err, ctx)
+	}
+	if err != nil {
+		responses.SendError(err, ctx)
 		return
 	}
 
@@ -88,30 +142,28 @@ func (ur *userHandler) Update(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	newUser := models.User{Nickname: nickname}
+	if err := ur.checkSelf(ctx, nickname); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
 
+	var newUser models.User
 	err := json.Unmarshal(ctx.PostBody(), &newUser)
 	if err != nil {
-		responses.SendServerError(err.Error(), ctx)
+		responses.SendError(apperrors.Validation("Invalid user: %s", err), ctx)
 		return
 	}
-
-	userDB, err := ur.userRepo.Update(newUser)
-	if pgerr, ok := err.(pgx.PgError); ok {
-		switch pgerr.Code {
-		case "23505":
-			err := responses.HttpError{
-				Message: fmt.Sprintf("This email is already registered by user: %s", newUser.Email),
-			}
-			responses.SendResponse(409, err, ctx)
-			return
-		}
+	// checkSelf cleared the path nickname, so a nickname in the body can't
+	// pick the account.
+	newUser.Nickname = nickname
+	if isTombstoneEmail(newUser.Email) {
+		responses.SendError(apperrors.Validation("Email %s is reserved", newUser.Email), ctx)
+		return
 	}
+
+	userDB, err := ur.userRepo.Update(ctx, newUser)
 	if err != nil {
-		err := responses.HttpError{
-			Message: fmt.Sprintf("Can't find user by nickname: %s", newUser.Nickname),
-		}
-		responses.SendResponse(404, err, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
@@ -119,6 +171,206 @@ func (ur *userHandler) Update(ctx *fasthttp.RequestCtx) {
 	return
 }
 
+func (ur *userHandler) Rename(ctx *fasthttp.RequestCtx) {
+	nickname, found := ctx.UserValue("nickname").(string)
+	if !found {
+		responses.SendResponse(400, "bad request", ctx)
+		return
+	}
+
+	if err := checkOwner(ctx, nickname); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	var rename struct {
+		Nickname string `json:"nickname"`
+	}
+	if err := json.Unmarshal(ctx.PostBody(), &rename); err != nil {
+		responses.SendError(apperrors.Validation("Invalid rename: %s", err), ctx)
+		return
+	}
+	if rename.Nickname == "" {
+		responses.SendError(apperrors.Validation("New nickname must not be empty"), ctx)
+		return
+	}
+	if isTombstone(nickname) || isTombstone(rename.Nickname) {
+		responses.SendError(apperrors.Validation("Nickname %s is reserved", models.Tombstone.Nickname), ctx)
+		return
+	}
+
+	userObj, err := ur.userRepo.Rename(ctx, nickname, rename.Nickname)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	responses.SendResponseOK(userObj, ctx)
+}
+
+func isTombstone(nickname string) bool {
+	return strings.EqualFold(nickname, models.Tombstone.Nickname)
+}
+
+// isTombstoneEmail keeps the tombstone email free, as the tombstone user is
+// created on the first anonymizing delete and would clash with its holder.
+func isTombstoneEmail(email string) bool {
+	return strings.EqualFold(email, models.Tombstone.Email)
+}
+
+// checkSelf lets a logged in user manage only their own account, and admins
+// any account. Unless auth is required, anonymous requests keep their old
+// access to accounts without a password; one with a password needs a login,
+// and a banned one is refused as its sessions are.
+func (ur *userHandler) checkSelf(ctx *fasthttp.RequestCtx, nickname string) error {
+	current := identity.FromContext(ctx)
+	switch {
+	case current == "" && ur.requireAuth:
+		return apperrors.Unauthorized("Log in to manage user %s", nickname)
+	case current == "":
+		userObj, err := ur.userRepo.GetByNick(ctx, nickname)
+		switch {
+		case errors.Is(err, apperrors.ErrNotFound):
+			return nil
+		case err != nil:
+			return err
+		case userObj.PasswordHash != "":
+			return apperrors.Unauthorized("Log in to manage user %s", nickname)
+		case userObj.Role == models.RoleBanned:
+			return apperrors.Forbidden("User %s is banned", userObj.Nickname)
+		}
+		return nil
+	case identity.IsAdmin(ctx), strings.EqualFold(current, nickname):
+		return nil
+	default:
+		return apperrors.Forbidden("Only %s or an admin can manage this user", nickname)
+	}
+}
+
+// checkOwner lets a logged in user manage their own account and admins any
+// account. It guards the routes that never had anonymous access, so it needs
+// a logged in user even when auth is not required.
+func checkOwner(ctx *fasthttp.RequestCtx, nickname string) error {
+	current := identity.FromContext(ctx)
+	switch {
+	case current == "":
+		return apperrors.Unauthorized("Log in to manage user %s", nickname)
+	case identity.IsAdmin(ctx), strings.EqualFold(current, nickname):
+		return nil
+	default:
+		return apperrors.Forbidden("Only %s or an admin can manage this user", nickname)
+	}
+}
+
+func (ur *userHandler) GetRole(ctx *fasthttp.RequestCtx) {
+	nickname, _ := ctx.UserValue("nickname").(string)
+	userObj, err := ur.userRepo.GetByNick(ctx, nickname)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	responses.SendResponseOK(models.UserRole{Nickname: userObj.Nickname, Role: userObj.Role}, ctx)
+}
+
+// SetRole changes the site role of a user. Only admins may do that, so it
+// needs a logged in user even when auth is not required.
+func (ur *userHandler) SetRole(ctx *fasthttp.RequestCtx) {
+	nickname, _ := ctx.UserValue("nickname").(string)
+	switch {
+	case identity.FromContext(ctx) == "":
+		responses.SendError(apperrors.Unauthorized("Log in to change roles"), ctx)
+		return
+	case !identity.IsAdmin(ctx):
+		responses.SendError(apperrors.Forbidden("Only admins can change roles"), ctx)
+		return
+	case isTombstone(nickname):
+		responses.SendError(apperrors.Validation("Role of %s can't be changed", nickname), ctx)
+		return
+	}
+
+	var request models.UserRole
+	if err := json.Unmarshal(ctx.PostBody(), &request); err != nil {
+		responses.SendError(apperrors.Validation("Invalid role: %s", err), ctx)
+		return
+	}
+	if !request.Role.Valid() {
+		responses.SendError(apperrors.Validation("Unknown role: %s", request.Role), ctx)
+		return
+	}
+
+	userObj, err := ur.userRepo.SetRole(ctx, nickname, request.Role)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+	responses.SendResponseOK(models.UserRole{Nickname: userObj.Nickname, Role: userObj.Role}, ctx)
+}
+
+// Delete removes the user, handing their content over to the tombstone user
+// unless mode=cascade asks to remove it as well.
+func (ur *userHandler) Delete(ctx *fasthttp.RequestCtx) {
+	nickname, found := ctx.UserValue("nickname").(string)
+	if !found {
+		responses.SendResponse(400, "bad request", ctx)
+		return
+	}
+	if isTombstone(nickname) {
+		responses.SendError(apperrors.Validation("User %s can't be deleted", nickname), ctx)
+		return
+	}
+	if err := checkOwner(ctx, nickname); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	mode := models.DeleteMode(ctx.QueryArgs().Peek("mode"))
+	if mode == "" {
+		mode = models.DeleteAnonymize
+	}
+
+	result, err := ur.userRepo.Delete(ctx, nickname, mode)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	responses.SendResponseOK(result, ctx)
+}
+
+// Export streams the personal data of the user. The body is written after the
+// handler returns, so a failure half way can only be logged and leaves the
+// client with a truncated document.
+func (ur *userHandler) Export(ctx *fasthttp.RequestCtx) {
+	nickname, found := ctx.UserValue("nickname").(string)
+	if !found {
+		responses.SendResponse(400, "bad request", ctx)
+		return
+	}
+
+	if err := checkOwner(ctx, nickname); err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	userObj, err := ur.userRepo.GetByNick(ctx, nickname)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	// The request context is reused once the handler returns.
+	exportCtx := context.WithValue(context.Background(), requestid.Key, requestid.FromContext(ctx))
+	ctx.SetContentType("application/json")
+	ctx.Response.Header.Set("Content-Disposition", `attachment; filename="user-export.json"`)
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := ur.userRepo.Export(exportCtx, userObj.Nickname, w); err != nil {
+			logger := requestid.Logger(exportCtx)
+			logger.Error().Str("error", err.Error()).Msg("user export failed")
+		}
+	})
+}
+
 func extractBoolValue(ctx *fasthttp.RequestCtx, valueName string) (bool, error) {
 	ValueStr := string(ctx.QueryArgs().Peek(valueName))
 	var value bool
@@ -161,7 +413,7 @@ func (ur *userHandler) GetByForum(ctx *fasthttp.RequestCtx) {
 
 	limit, err := extractIntValue(ctx, "limit")
 	if err != nil {
-		responses.SendResponse(400, err, ctx)
+		responses.SendError(apperrors.Validation("Invalid limit: %s", err), ctx)
 		return
 	}
 
@@ -169,20 +421,20 @@ func (ur *userHandler) GetByForum(ctx *fasthttp.RequestCtx) {
 
 	desc, err := extractBoolValue(ctx, "desc")
 	if err != nil {
-		responses.SendResponse(400, err, ctx)
+		responses.SendError(apperrors.Validation("Invalid desc: %s", err), ctx)
 		return
 	}
 
-	users, err := ur.userRepo.GetUsersByForum(slug, limit, since, desc)
+	users, err := ur.userRepo.GetUsersByForum(ctx, slug, limit, since, desc)
 	if err != nil {
-		responses.SendResponse(404, err, ctx)
+		responses.SendError(err, ctx)
 		return
 	}
 
 	if users == nil {
-		_, err = ur.forumRepo.GetBySlug(slug)
+		_, err = ur.forumRepo.GetBySlug(ctx, slug)
 		if err != nil {
-			responses.SendResponse(404, err, ctx)
+			responses.SendError(err, ctx)
 			return
 		}
 		responses.SendResponseOK([]models.User{}, ctx)
@@ -192,3 +444,32 @@ func (ur *userHandler) GetByForum(ctx *fasthttp.RequestCtx) {
 	responses.SendResponseOK(users, ctx)
 	return
 }
+
+func (ur *userHandler) GetUsers(ctx *fasthttp.RequestCtx) {
+	prefix := string(ctx.QueryArgs().Peek("prefix"))
+
+	limit, err := extractIntValue(ctx, "limit")
+	if err != nil {
+		responses.SendError(apperrors.Validation("Invalid limit: %s", err), ctx)
+		return
+	}
+
+	since := string(ctx.QueryArgs().Peek("since"))
+
+	desc, err := extractBoolValue(ctx, "desc")
+	if err != nil {
+		responses.SendError(apperrors.Validation("Invalid desc: %s", err), ctx)
+		return
+	}
+
+	users, err := ur.userRepo.GetUsers(ctx, prefix, limit, since, desc)
+	if err != nil {
+		responses.SendError(err, ctx)
+		return
+	}
+
+	if users == nil {
+		users = []models.User{}
+	}
+	responses.SendResponseOK(users, ctx)
+}
@@ -5,4 +5,71 @@ type User struct {
 	Email    string `json:"email"`
 	FullName string `json:"fullname"`
 	Nickname string `json:"nickname"`
+	// PasswordHash is the bcrypt hash of the password, empty for accounts
+	// created without one. It is never sent to clients.
+	PasswordHash string `json:"-"`
+	// Role is the site role of the user. Only GetByNick and SetRole load it.
+	Role Role `json:"-"`
+}
+
+// Role is what a user may do across the site. Moderators are assigned per
+// forum on top of the site role.
+type Role string
+
+const (
+	// RoleAdmin may change anything, including roles and the service routes.
+	RoleAdmin Role = "admin"
+	// RoleMember writes and edits their own content, the default.
+	RoleMember Role = "member"
+	// RoleBanned may only read.
+	RoleBanned Role = "banned"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleAdmin || r == RoleMember || r == RoleBanned
+}
+
+// UserRole is the answer to reading or setting the role of a user.
+type UserRole struct {
+	Nickname string `json:"nickname"`
+	Role     Role   `json:"role"`
+}
+
+// DeleteMode selects what happens to the content of a deleted user.
+type DeleteMode string
+
+const (
+	// DeleteAnonymize hands the forums, threads and posts of the user over
+	// to the Tombstone user. Votes can't be merged and are removed.
+	DeleteAnonymize DeleteMode = "anonymize"
+	// DeleteCascade removes the forums, threads, posts and votes of the user
+	// together with everything in those forums and threads and the replies
+	// to the removed posts.
+	DeleteCascade DeleteMode = "cascade"
+)
+
+// Tombstone is the reserved user that keeps the content of anonymized users.
+var Tombstone = User{
+	About:    "",
+	Email:    "deleted@invalid",
+	FullName: "Deleted user",
+	Nickname: "[deleted]",
+	Role:     RoleMember,
+}
+
+// DeleteResult counts the rows that were handed over to the tombstone user
+// or removed; votes are always removed.
+type DeleteResult struct {
+	Nickname string     `json:"nickname"`
+	Mode     DeleteMode `json:"mode"`
+	Forums   int64      `json:"forums"`
+	Threads  int64      `json:"threads"`
+	Posts    int64      `json:"posts"`
+	Votes    int64      `json:"votes"`
+}
+
+// ExportVote is a vote of the user in a personal data export.
+type ExportVote struct {
+	Thread int64 `json:"thread"`
+	Voice  int32 `json:"voice"`
 }
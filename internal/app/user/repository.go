@@ -1,13 +1,33 @@
 package user
 
-import "DbProjectForum/internal/app/user/models"
+import (
+	"DbProjectForum/internal/app/user/models"
+	"context"
+	"io"
+)
 
 type Repository interface {
-	Add(user models.User) error
+	Add(ctx context.Context, user models.User) error
 
-	GetByNickAndEmail(nickname, email string) ([]models.User, error)
-	GetByNick(nickname string) (models.User, error)
-	GetUsersByForum(slug string, limit int, since string, desc bool) ([]models.User, error)
+	GetByNickAndEmail(ctx context.Context, nickname, email string) ([]models.User, error)
+	GetByNick(ctx context.Context, nickname string) (models.User, error)
+	GetUsersByForum(ctx context.Context, slug string, limit int, since string, desc bool) ([]models.User, error)
+	// GetUsers lists all users whose nickname or fullname starts with prefix,
+	// ignoring case, ordered by nickname.
+	GetUsers(ctx context.Context, prefix string, limit int, since string, desc bool) ([]models.User, error)
+	// GetModerators lists the moderators of a forum, not counting its owner,
+	// ordered by nickname.
+	GetModerators(ctx context.Context, slug string) ([]models.User, error)
 
-	Update(user models.User) (models.User, error)
+	Update(ctx context.Context, user models.User) (models.User, error)
+	SetRole(ctx context.Context, nickname string, role models.Role) (models.User, error)
+	// Rename changes the nickname of the user everywhere it is stored and
+	// keeps the old one as an alias.
+	Rename(ctx context.Context, nickname, newNickname string) (models.User, error)
+	// GetByAlias finds the user that used to be called alias.
+	GetByAlias(ctx context.Context, alias string) (models.User, error)
+
+	Delete(ctx context.Context, nickname string, mode models.DeleteMode) (models.DeleteResult, error)
+	// Export writes the personal data of the user to w as one JSON object.
+	Export(ctx context.Context, nickname string, w io.Writer) error
 }
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/user/models"
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// Sections of a personal data export in the order they are written.
+const (
+	exportForums  = "forums"
+	exportThreads = "threads"
+	exportPosts   = "posts"
+	exportVotes   = "votes"
+)
+
+// exportWriter streams a personal data export as a single JSON object,
+//
+//	{"user":{...},"forums":[...],"threads":[...],"posts":[...],"votes":[...]}
+//
+// writing the sections one element at a time so that the export is never
+// held in memory as a whole. The first error is kept and stops all writes.
+type exportWriter struct {
+	w     *bufio.Writer
+	enc   *json.Encoder
+	items int
+	open  bool
+	err   error
+}
+
+func newExportWriter(w io.Writer, user models.User) *exportWriter {
+	e := &exportWriter{w: bufio.NewWriter(w)}
+	e.enc = json.NewEncoder(e.w)
+	e.write(`{"user":`)
+	e.encode(user)
+	return e
+}
+
+func (e *exportWriter) write(s string) {
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+func (e *exportWriter) encode(value interface{}) {
+	if e.err == nil {
+		e.err = e.enc.Encode(value)
+	}
+}
+
+// section closes the previous section and starts the array of name.
+func (e *exportWriter) section(name string) {
+	if e.open {
+		e.write("]")
+	}
+	e.write(`,"` + name + `":[`)
+	e.open = true
+	e.items = 0
+}
+
+func (e *exportWriter) item(value interface{}) error {
+	if e.items > 0 {
+		e.write(",")
+	}
+	e.encode(value)
+	e.items++
+	return e.err
+}
+
+func (e *exportWriter) close() error {
+	if e.open {
+		e.write("]")
+	}
+	e.write("}\n")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
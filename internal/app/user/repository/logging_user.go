@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/user"
+	"DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/requestid"
+	"context"
+	"io"
+	"time"
+)
+
+// loggingUserRepository logs every repository call with the ID of the HTTP request
+// that caused it and warns about calls slower than slowQuery.
+type loggingUserRepository struct {
+	next      user.Repository
+	slowQuery time.Duration
+}
+
+func NewLoggingUserRepository(next user.Repository, slowQuery time.Duration) user.Repository {
+	return &loggingUserRepository{
+		next:      next,
+		slowQuery: slowQuery,
+	}
+}
+
+func (l *loggingUserRepository) observe(ctx context.Context, method string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	logger := requestid.Logger(ctx)
+
+	event := logger.Debug()
+	if elapsed >= l.slowQuery {
+		event = logger.Warn()
+	}
+	if err != nil {
+		event = event.Str("error", err.Error())
+	}
+	event.Str("repository", "user").
+		Str("method", method).
+		Dur("latency", elapsed).
+		Msg("repository call")
+}
+
+func (l *loggingUserRepository) Add(ctx context.Context, user models.User) error {
+	start := time.Now()
+	err := l.next.Add(ctx, user)
+	l.observe(ctx, "Add", start, err)
+	return err
+}
+
+func (l *loggingUserRepository) GetByNickAndEmail(ctx context.Context, nickname, email string) ([]models.User, error) {
+	start := time.Now()
+	result, err := l.next.GetByNickAndEmail(ctx, nickname, email)
+	l.observe(ctx, "GetByNickAndEmail", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) GetByNick(ctx context.Context, nickname string) (models.User, error) {
+	start := time.Now()
+	result, err := l.next.GetByNick(ctx, nickname)
+	l.observe(ctx, "GetByNick", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) GetUsersByForum(ctx context.Context, slug string, limit int, since string, desc bool) ([]models.User, error) {
+	start := time.Now()
+	result, err := l.next.GetUsersByForum(ctx, slug, limit, since, desc)
+	l.observe(ctx, "GetUsersByForum", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) GetUsers(ctx context.Context, prefix string, limit int, since string, desc bool) ([]models.User, error) {
+	start := time.Now()
+	result, err := l.next.GetUsers(ctx, prefix, limit, since, desc)
+	l.observe(ctx, "GetUsers", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) GetModerators(ctx context.Context, slug string) ([]models.User, error) {
+	start := time.Now()
+	result, err := l.next.GetModerators(ctx, slug)
+	l.observe(ctx, "GetModerators", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) SetRole(ctx context.Context, nickname string, role models.Role) (models.User, error) {
+	start := time.Now()
+	result, err := l.next.SetRole(ctx, nickname, role)
+	l.observe(ctx, "SetRole", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) Update(ctx context.Context, user models.User) (models.User, error) {
+	start := time.Now()
+	result, err := l.next.Update(ctx, user)
+	l.observe(ctx, "Update", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) Rename(ctx context.Context, nickname, newNickname string) (models.User, error) {
+	start := time.Now()
+	result, err := l.next.Rename(ctx, nickname, newNickname)
+	l.observe(ctx, "Rename", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) GetByAlias(ctx context.Context, alias string) (models.User, error) {
+	start := time.Now()
+	result, err := l.next.GetByAlias(ctx, alias)
+	l.observe(ctx, "GetByAlias", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) Delete(ctx context.Context, nickname string, mode models.DeleteMode) (models.DeleteResult, error) {
+	start := time.Now()
+	result, err := l.next.Delete(ctx, nickname, mode)
+	l.observe(ctx, "Delete", start, err)
+	return result, err
+}
+
+func (l *loggingUserRepository) Export(ctx context.Context, nickname string, w io.Writer) error {
+	start := time.Now()
+	err := l.next.Export(ctx, nickname, w)
+	l.observe(ctx, "Export", start, err)
+	return err
+}
@@ -0,0 +1,527 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/user"
+	"DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/memstore"
+	"context"
+	"github.com/go-openapi/strfmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type memoryUserRepository struct {
+	store *memstore.Store
+}
+
+func NewMemoryUserRepository(store *memstore.Store) user.Repository {
+	return &memoryUserRepository{store: store}
+}
+
+func (m *memoryUserRepository) emailTaken(email, exceptNickname string) bool {
+	for key, userObj := range m.store.Users {
+		if key != memstore.Key(exceptNickname) && memstore.Key(userObj.Email) == memstore.Key(email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memoryUserRepository) Add(ctx context.Context, user models.User) error {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	if _, ok := m.store.Users[memstore.Key(user.Nickname)]; ok || m.emailTaken(user.Email, "") {
+		return apperrors.Conflict("User with nickname %s or email %s already exists", user.Nickname, user.Email)
+	}
+	userObj := user
+	userObj.Nickname = memstore.Copy(user.Nickname)
+	if userObj.Role == "" {
+		userObj.Role = models.RoleMember
+	}
+	m.store.Users[memstore.Key(user.Nickname)] = &userObj
+	return nil
+}
+
+func (m *memoryUserRepository) GetByNickAndEmail(ctx context.Context, nickname, email string) ([]models.User, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	var data []models.User
+	for key, userObj := range m.store.Users {
+		if key == memstore.Key(nickname) || memstore.Key(userObj.Email) == memstore.Key(email) {
+			data = append(data, *userObj)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return memstore.Key(data[i].Nickname) < memstore.Key(data[j].Nickname)
+	})
+	return data, nil
+}
+
+func (m *memoryUserRepository) GetByNick(ctx context.Context, nickname string) (models.User, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	userObj, ok := m.store.Users[memstore.Key(nickname)]
+	if !ok {
+		return models.User{}, apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	return *userObj, nil
+}
+
+func (m *memoryUserRepository) GetUsersByForum(ctx context.Context, slug string, limit int, since string,
+	desc bool) ([]models.User, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	var data []models.User
+	for key := range m.store.UsersForum[memstore.Key(slug)] {
+		switch {
+		case since != "" && desc && key >= memstore.Key(since):
+			continue
+		case since != "" && !desc && key <= memstore.Key(since):
+			continue
+		}
+		data = append(data, *m.store.Users[key])
+	}
+
+	sort.Slice(data, func(i, j int) bool {
+		if desc {
+			return memstore.Key(data[i].Nickname) > memstore.Key(data[j].Nickname)
+		}
+		return memstore.Key(data[i].Nickname) < memstore.Key(data[j].Nickname)
+	})
+	if limit > 0 && len(data) > limit {
+		data = data[:limit]
+	}
+	return data, nil
+}
+
+func (m *memoryUserRepository) GetModerators(ctx context.Context, slug string) ([]models.User, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	data := make([]models.User, 0)
+	for key := range m.store.Moderators[memstore.Key(slug)] {
+		data = append(data, *m.store.Users[key])
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return memstore.Key(data[i].Nickname) < memstore.Key(data[j].Nickname)
+	})
+	return data, nil
+}
+
+func (m *memoryUserRepository) GetUsers(ctx context.Context, prefix string, limit int, since string,
+	desc bool) ([]models.User, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	prefix = memstore.Key(prefix)
+	var data []models.User
+	for key, userObj := range m.store.Users {
+		switch {
+		case !strings.HasPrefix(key, prefix) && !strings.HasPrefix(memstore.Key(userObj.FullName), prefix):
+			continue
+		case since != "" && desc && key >= memstore.Key(since):
+			continue
+		case since != "" && !desc && key <= memstore.Key(since):
+			continue
+		}
+		data = append(data, *userObj)
+	}
+
+	sort.Slice(data, func(i, j int) bool {
+		if desc {
+			return memstore.Key(data[i].Nickname) > memstore.Key(data[j].Nickname)
+		}
+		return memstore.Key(data[i].Nickname) < memstore.Key(data[j].Nickname)
+	})
+	if limit > 0 && len(data) > limit {
+		data = data[:limit]
+	}
+	return data, nil
+}
+
+func (m *memoryUserRepository) Update(ctx context.Context, user models.User) (models.User, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	userObj, ok := m.store.Users[memstore.Key(user.Nickname)]
+	if !ok {
+		return models.User{}, apperrors.NotFound("Can't find user by nickname: %s", user.Nickname)
+	}
+	if user.Email != "" && m.emailTaken(user.Email, user.Nickname) {
+		return models.User{}, apperrors.Conflict("This email is already registered by user: %s", user.Email)
+	}
+
+	if user.About != "" {
+		userObj.About = user.About
+	}
+	if user.Email != "" {
+		userObj.Email = user.Email
+	}
+	if user.FullName != "" {
+		userObj.FullName = user.FullName
+	}
+	return *userObj, nil
+}
+
+func (m *memoryUserRepository) SetRole(ctx context.Context, nickname string, role models.Role) (models.User, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	userObj, ok := m.store.Users[memstore.Key(nickname)]
+	if !ok {
+		return models.User{}, apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	userObj.Role = role
+	return *userObj, nil
+}
+
+func (m *memoryUserRepository) Rename(ctx context.Context, nickname, newNickname string) (models.User, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	key, newKey := memstore.Key(nickname), memstore.Key(newNickname)
+	userObj, ok := m.store.Users[key]
+	if !ok {
+		return models.User{}, apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	if _, taken := m.store.Users[newKey]; taken && newKey != key {
+		return models.User{}, apperrors.Conflict("Nickname %s is already taken", newNickname)
+	}
+
+	newNickname = memstore.Copy(newNickname)
+	userObj.Nickname = newNickname
+	delete(m.store.Users, key)
+	m.store.Users[newKey] = userObj
+
+	for _, forumObj := range m.store.Forums {
+		if memstore.Key(forumObj.User) == key {
+			forumObj.User = newNickname
+		}
+	}
+	for _, thread := range m.store.Threads {
+		if thread != nil && memstore.Key(thread.Author) == key {
+			thread.Author = newNickname
+		}
+	}
+	for _, post := range m.store.Posts {
+		if post != nil && memstore.Key(post.Author) == key {
+			post.Author = newNickname
+		}
+	}
+	for voteKey, voice := range m.store.Votes {
+		if voteKey.Nickname == key && newKey != key {
+			delete(m.store.Votes, voteKey)
+			m.store.Votes[memstore.VoteKey{Nickname: newKey, Thread: voteKey.Thread}] = voice
+		}
+	}
+	for _, participants := range m.store.UsersForum {
+		if participants[key] {
+			delete(participants, key)
+			participants[newKey] = true
+		}
+	}
+	for _, moderators := range m.store.Moderators {
+		if moderators[key] {
+			delete(moderators, key)
+			moderators[newKey] = true
+		}
+	}
+
+	for tokenHash, session := range m.store.Sessions {
+		if memstore.Key(session.Nickname) == key {
+			session.Nickname = newNickname
+			m.store.Sessions[tokenHash] = session
+		}
+	}
+	for tokenHash, token := range m.store.APITokens {
+		if memstore.Key(token.Nickname) == key {
+			token.Nickname = newNickname
+			m.store.APITokens[tokenHash] = token
+		}
+	}
+
+	delete(m.store.Aliases, newKey)
+	for alias, target := range m.store.Aliases {
+		if target == key {
+			m.store.Aliases[alias] = newKey
+		}
+	}
+	// When only the case changed, the old nickname still finds the user.
+	if newKey != key {
+		m.store.Aliases[key] = newKey
+	}
+	return *userObj, nil
+}
+
+func (m *memoryUserRepository) GetByAlias(ctx context.Context, alias string) (models.User, error) {
+	m.store.RLock()
+	defer m.store.RUnlock()
+
+	userObj, ok := m.store.Users[m.store.Aliases[memstore.Key(alias)]]
+	if !ok {
+		return models.User{}, apperrors.NotFound("Can't find user by alias: %s", alias)
+	}
+	return *userObj, nil
+}
+
+func (m *memoryUserRepository) Delete(ctx context.Context, nickname string, mode models.DeleteMode) (models.DeleteResult, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+
+	key := memstore.Key(nickname)
+	userObj, ok := m.store.Users[key]
+	if !ok {
+		return models.DeleteResult{}, apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+
+	result := models.DeleteResult{Nickname: userObj.Nickname, Mode: mode}
+	var err error
+	switch mode {
+	case models.DeleteAnonymize:
+		err = m.anonymize(key, &result)
+	case models.DeleteCascade:
+		m.cascade(key, &result)
+	default:
+		err = apperrors.Validation("Unknown delete mode: %s", mode)
+	}
+	if err != nil {
+		return models.DeleteResult{}, err
+	}
+
+	delete(m.store.Users, key)
+	for alias, target := range m.store.Aliases {
+		if target == key {
+			delete(m.store.Aliases, alias)
+		}
+	}
+	for tokenHash, session := range m.store.Sessions {
+		if memstore.Key(session.Nickname) == key {
+			delete(m.store.Sessions, tokenHash)
+		}
+	}
+	for tokenHash, token := range m.store.APITokens {
+		if memstore.Key(token.Nickname) == key {
+			delete(m.store.APITokens, tokenHash)
+		}
+	}
+	for _, moderators := range m.store.Moderators {
+		delete(moderators, key)
+	}
+	return result, nil
+}
+
+// deleteVotes removes the votes of the user and the votes on the threads
+// matched by onThread, subtracting them from the threads that remain.
+func (m *memoryUserRepository) deleteVotes(key string, onThread func(id int64) bool, result *models.DeleteResult) {
+	for voteKey, voice := range m.store.Votes {
+		if voteKey.Nickname != key && !onThread(voteKey.Thread) {
+			continue
+		}
+		if thread, ok := m.store.Thread(int(voteKey.Thread)); ok {
+			thread.Votes -= voice
+		}
+		delete(m.store.Votes, voteKey)
+		result.Votes++
+	}
+}
+
+func (m *memoryUserRepository) anonymize(key string, result *models.DeleteResult) error {
+	tombstoneKey := memstore.Key(models.Tombstone.Nickname)
+	if _, ok := m.store.Users[tombstoneKey]; !ok {
+		if m.emailTaken(models.Tombstone.Email, "") {
+			return apperrors.Conflict("Tombstone email %s is taken", models.Tombstone.Email)
+		}
+		tombstone := models.Tombstone
+		m.store.Users[tombstoneKey] = &tombstone
+	}
+
+	for _, forumObj := range m.store.Forums {
+		if memstore.Key(forumObj.User) == key {
+			forumObj.User = models.Tombstone.Nickname
+			result.Forums++
+		}
+	}
+	for _, thread := range m.store.Threads {
+		if thread != nil && memstore.Key(thread.Author) == key {
+			thread.Author = models.Tombstone.Nickname
+			result.Threads++
+		}
+	}
+	for _, post := range m.store.Posts {
+		if post != nil && memstore.Key(post.Author) == key {
+			post.Author = models.Tombstone.Nickname
+			result.Posts++
+		}
+	}
+	m.deleteVotes(key, func(int64) bool { return false }, result)
+
+	for _, participants := range m.store.UsersForum {
+		if participants[key] {
+			delete(participants, key)
+			participants[tombstoneKey] = true
+		}
+	}
+	return nil
+}
+
+func (m *memoryUserRepository) cascade(key string, result *models.DeleteResult) {
+	forums := make(map[string]bool)
+	for forumKey, forumObj := range m.store.Forums {
+		if memstore.Key(forumObj.User) == key {
+			forums[forumKey] = true
+		}
+	}
+
+	threads := make(map[int64]bool)
+	for _, thread := range m.store.Threads {
+		if thread != nil && (memstore.Key(thread.Author) == key || forums[memstore.Key(thread.Forum)]) {
+			threads[int64(thread.Id)] = true
+		}
+	}
+
+	authored := make(map[int64]bool)
+	for _, post := range m.store.Posts {
+		if post != nil && memstore.Key(post.Author) == key {
+			authored[post.Id] = true
+		}
+	}
+
+	// Forums keep their counters in sync unless they are removed as well;
+	// affected records the forums whose participants may have changed.
+	affected := make(map[string]bool)
+	for _, post := range m.store.Posts {
+		if post == nil || !threads[int64(post.Thread)] && !inPath(post.PathIDs, authored) {
+			continue
+		}
+		forumKey := memstore.Key(post.Forum)
+		if forumObj, ok := m.store.Forums[forumKey]; ok && !post.IsDeleted {
+			forumObj.Posts--
+		}
+		affected[forumKey] = true
+		m.store.DeletePost(post.Id)
+		result.Posts++
+	}
+
+	m.deleteVotes(key, func(id int64) bool { return threads[id] }, result)
+
+	for id := range threads {
+		thread, _ := m.store.Thread(int(id))
+		forumKey := memstore.Key(thread.Forum)
+		m.store.Forums[forumKey].Threads--
+		affected[forumKey] = true
+		m.store.DeleteThread(int(id))
+		result.Threads++
+	}
+
+	for forumKey := range affected {
+		m.removeIdleParticipants(forumKey)
+	}
+	for forumKey := range forums {
+		delete(m.store.Forums, forumKey)
+		delete(m.store.UsersForum, forumKey)
+		delete(m.store.Moderators, forumKey)
+		result.Forums++
+	}
+	for _, participants := range m.store.UsersForum {
+		delete(participants, key)
+	}
+}
+
+func inPath(path []int64, ids map[int64]bool) bool {
+	for _, id := range path {
+		if ids[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// removeIdleParticipants drops the participants of the forum that no longer
+// have a thread or a post in it.
+func (m *memoryUserRepository) removeIdleParticipants(forumKey string) {
+	active := make(map[string]bool)
+	for _, thread := range m.store.Threads {
+		if thread != nil && memstore.Key(thread.Forum) == forumKey {
+			active[memstore.Key(thread.Author)] = true
+		}
+	}
+	for _, post := range m.store.Posts {
+		if post != nil && memstore.Key(post.Forum) == forumKey {
+			active[memstore.Key(post.Author)] = true
+		}
+	}
+	for nickname := range m.store.UsersForum[forumKey] {
+		if !active[nickname] {
+			delete(m.store.UsersForum[forumKey], nickname)
+		}
+	}
+}
+
+// Export copies the data of the user under the read lock and writes it once
+// the lock is released, so a slow reader doesn't hold back writers.
+func (m *memoryUserRepository) Export(ctx context.Context, nickname string, w io.Writer) error {
+	m.store.RLock()
+	key := memstore.Key(nickname)
+	userObj, ok := m.store.Users[key]
+	if !ok {
+		m.store.RUnlock()
+		return apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+
+	sections := map[string][]interface{}{}
+	var forumKeys []string
+	for forumKey, participants := range m.store.UsersForum {
+		if participants[key] {
+			forumKeys = append(forumKeys, forumKey)
+		}
+	}
+	sort.Strings(forumKeys)
+	for _, forumKey := range forumKeys {
+		if forumObj, ok := m.store.Forums[forumKey]; ok {
+			sections[exportForums] = append(sections[exportForums], *forumObj)
+		}
+	}
+	for _, thread := range m.store.Threads {
+		if thread != nil && memstore.Key(thread.Author) == key {
+			threadObj := thread.Thread
+			threadObj.Created = strfmt.DateTime(thread.CreatedAt.UTC()).String()
+			sections[exportThreads] = append(sections[exportThreads], threadObj)
+		}
+	}
+	for _, post := range m.store.Posts {
+		if post != nil && memstore.Key(post.Author) == key {
+			postObj := post.Post
+			postObj.Created = strfmt.DateTime(post.CreatedAt.UTC()).String()
+			sections[exportPosts] = append(sections[exportPosts], postObj)
+		}
+	}
+	var votes []models.ExportVote
+	for voteKey, voice := range m.store.Votes {
+		if voteKey.Nickname == key {
+			votes = append(votes, models.ExportVote{Thread: voteKey.Thread, Voice: voice})
+		}
+	}
+	sort.Slice(votes, func(i, j int) bool { return votes[i].Thread < votes[j].Thread })
+	for _, vote := range votes {
+		sections[exportVotes] = append(sections[exportVotes], vote)
+	}
+	profile := *userObj
+	m.store.RUnlock()
+
+	e := newExportWriter(w, profile)
+	for _, name := range []string{exportForums, exportThreads, exportPosts, exportVotes} {
+		e.section(name)
+		for _, value := range sections[name] {
+			if err := e.item(value); err != nil {
+				return err
+			}
+		}
+	}
+	return e.close()
+}
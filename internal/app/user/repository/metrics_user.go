@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/user"
+	"DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/metrics"
+	"context"
+	"io"
+	"time"
+)
+
+// metricsUserRepository records the latency of every repository call.
+type metricsUserRepository struct {
+	next user.Repository
+}
+
+func NewMetricsUserRepository(next user.Repository) user.Repository {
+	return &metricsUserRepository{next: next}
+}
+
+func (m *metricsUserRepository) observe(method string, start time.Time, err error) {
+	metrics.RepositoryDuration.WithLabelValues("user", method, metrics.Outcome(err)).
+		Observe(time.Since(start).Seconds())
+}
+
+func (m *metricsUserRepository) Add(ctx context.Context, user models.User) error {
+	start := time.Now()
+	err := m.next.Add(ctx, user)
+	m.observe("Add", start, err)
+	return err
+}
+
+func (m *metricsUserRepository) GetByNickAndEmail(ctx context.Context, nickname, email string) ([]models.User, error) {
+	start := time.Now()
+	result, err := m.next.GetByNickAndEmail(ctx, nickname, email)
+	m.observe("GetByNickAndEmail", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) GetByNick(ctx context.Context, nickname string) (models.User, error) {
+	start := time.Now()
+	result, err := m.next.GetByNick(ctx, nickname)
+	m.observe("GetByNick", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) GetUsersByForum(ctx context.Context, slug string, limit int, since string, desc bool) ([]models.User, error) {
+	start := time.Now()
+	result, err := m.next.GetUsersByForum(ctx, slug, limit, since, desc)
+	m.observe("GetUsersByForum", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) GetUsers(ctx context.Context, prefix string, limit int, since string, desc bool) ([]models.User, error) {
+	start := time.Now()
+	result, err := m.next.GetUsers(ctx, prefix, limit, since, desc)
+	m.observe("GetUsers", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) GetModerators(ctx context.Context, slug string) ([]models.User, error) {
+	start := time.Now()
+	result, err := m.next.GetModerators(ctx, slug)
+	m.observe("GetModerators", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) SetRole(ctx context.Context, nickname string, role models.Role) (models.User, error) {
+	start := time.Now()
+	result, err := m.next.SetRole(ctx, nickname, role)
+	m.observe("SetRole", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) Update(ctx context.Context, user models.User) (models.User, error) {
+	start := time.Now()
+	result, err := m.next.Update(ctx, user)
+	m.observe("Update", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) Rename(ctx context.Context, nickname, newNickname string) (models.User, error) {
+	start := time.Now()
+	result, err := m.next.Rename(ctx, nickname, newNickname)
+	m.observe("Rename", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) GetByAlias(ctx context.Context, alias string) (models.User, error) {
+	start := time.Now()
+	result, err := m.next.GetByAlias(ctx, alias)
+	m.observe("GetByAlias", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) Delete(ctx context.Context, nickname string, mode models.DeleteMode) (models.DeleteResult, error) {
+	start := time.Now()
+	result, err := m.next.Delete(ctx, nickname, mode)
+	m.observe("Delete", start, err)
+	return result, err
+}
+
+func (m *metricsUserRepository) Export(ctx context.Context, nickname string, w io.Writer) error {
+	start := time.Now()
+	err := m.next.Export(ctx, nickname, w)
+	m.observe("Export", start, err)
+	return err
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/requestctx"
+	"context"
+	"github.com/jackc/pgx"
+)
+
+// Deleting a user locks the user row first, so no new forum, thread, post or
+// vote can refer to them while the transaction runs. Cascade also locks the
+// rows it removes, which holds back new threads, posts and votes that would
+// refer to them.
+const (
+	lockUserQuery = `SELECT nickname FROM users WHERE nickname = $1 FOR UPDATE`
+
+	insertTombstoneQuery = `INSERT INTO users(about, email, fullname, nickname) VALUES ($1, $2, $3, $4)
+ON CONFLICT (nickname) DO NOTHING`
+
+	anonymizeForumsQuery  = `UPDATE forum SET "user" = $2 WHERE "user" = $1`
+	anonymizeThreadsQuery = `UPDATE thread SET author = $2 WHERE author = $1`
+	anonymizePostsQuery   = `UPDATE post SET author = $2 WHERE author = $1`
+
+	anonymizeUsersForumQuery = `INSERT INTO users_forum(nickname, slug)
+SELECT $2, slug FROM users_forum WHERE nickname = $1
+ON CONFLICT DO NOTHING`
+
+	deleteUserVotesQuery = `WITH removed AS (DELETE FROM vote WHERE nickname = $1 RETURNING idThread, voice)
+UPDATE thread SET votes = thread.votes - removed.voice
+FROM removed
+WHERE thread.id = removed.idThread`
+
+	ownedForums     = `SELECT slug FROM forum WHERE "user" = $1`
+	cascadedThreads = `SELECT id FROM thread WHERE author = $1 OR forum IN (` + ownedForums + `)`
+	cascadedPosts   = `SELECT id FROM post
+WHERE thread IN (` + cascadedThreads + `)
+   OR path && ARRAY(SELECT id FROM post WHERE author = $1)`
+
+	lockForumsQuery  = ownedForums + ` FOR UPDATE`
+	lockThreadsQuery = cascadedThreads + ` FOR UPDATE`
+	lockPostsQuery   = cascadedPosts + ` FOR UPDATE`
+
+	// Deleted posts are no longer counted in forum.posts.
+	subtractForumPostsQuery = `UPDATE forum SET posts = forum.posts - removed.n
+FROM (SELECT forum, COUNT(*) FILTER (WHERE NOT isDeleted) AS n FROM post
+      WHERE id IN (` + cascadedPosts + `) GROUP BY forum) removed
+WHERE forum.slug = removed.forum
+RETURNING forum.slug`
+
+	subtractForumThreadsQuery = `UPDATE forum SET threads = forum.threads - removed.n
+FROM (SELECT forum, COUNT(*) AS n FROM thread WHERE id IN (` + cascadedThreads + `) GROUP BY forum) removed
+WHERE forum.slug = removed.forum
+RETURNING forum.slug`
+
+	// Votes of the user on threads that stay are subtracted from them; votes
+	// on removed threads go away with the threads.
+	subtractThreadVotesQuery = `UPDATE thread SET votes = thread.votes - vote.voice
+FROM vote
+WHERE vote.idThread = thread.id
+  AND vote.nickname = $1
+  AND thread.id NOT IN (` + cascadedThreads + `)`
+
+	deleteCascadedVotesQuery   = `DELETE FROM vote WHERE nickname = $1 OR idThread IN (` + cascadedThreads + `)`
+	deleteCascadedPostsQuery   = `DELETE FROM post WHERE id IN (` + cascadedPosts + `)`
+	deleteCascadedThreadsQuery = `DELETE FROM thread WHERE id IN (` + cascadedThreads + `)`
+
+	deleteIdleParticipantsQuery = `DELETE FROM users_forum
+WHERE slug = ANY($1::text[]::citext[])
+  AND NOT EXISTS(SELECT 1 FROM thread WHERE thread.author = users_forum.nickname AND thread.forum = users_forum.slug)
+  AND NOT EXISTS(SELECT 1 FROM post WHERE post.author = users_forum.nickname AND post.forum = users_forum.slug)`
+
+	deleteParticipantQuery = `DELETE FROM users_forum WHERE nickname = $1 OR slug IN (` + ownedForums + `)`
+	deleteOwnedForumsQuery = `DELETE FROM forum WHERE "user" = $1`
+	deleteUserQuery        = `DELETE FROM users WHERE nickname = $1`
+)
+
+func (p *postgresUserRepository) Delete(ctx context.Context, nickname string, mode models.DeleteMode) (models.DeleteResult, error) {
+	if mode != models.DeleteAnonymize && mode != models.DeleteCascade {
+		return models.DeleteResult{}, apperrors.Validation("Unknown delete mode: %s", mode)
+	}
+
+	ctx, cancel := requestctx.Detach(ctx)
+	defer cancel()
+
+	tx, err := p.Conn.BeginEx(ctx, nil)
+	if err != nil {
+		return models.DeleteResult{}, apperrors.FromPg(err)
+	}
+	defer tx.Rollback()
+
+	result := models.DeleteResult{Mode: mode}
+	err = tx.QueryRow(lockUserQuery, nickname).Scan(&result.Nickname)
+	if err == pgx.ErrNoRows {
+		return models.DeleteResult{}, apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	if err != nil {
+		return models.DeleteResult{}, apperrors.FromPg(err)
+	}
+
+	if mode == models.DeleteAnonymize {
+		err = anonymize(tx, nickname, &result)
+	} else {
+		err = cascade(tx, nickname, &result)
+	}
+	if err == nil {
+		_, err = tx.Exec(deleteUserQuery, nickname)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return models.DeleteResult{}, apperrors.FromPg(err)
+	}
+	return result, nil
+}
+
+// step is a statement of a deletion and where to store the number of rows
+// it affected, if that is reported.
+type step struct {
+	query string
+	count *int64
+}
+
+func runSteps(tx *pgx.Tx, steps []step, args ...interface{}) error {
+	for _, step := range steps {
+		tag, err := tx.Exec(step.query, args...)
+		if err != nil {
+			return err
+		}
+		if step.count != nil {
+			*step.count = tag.RowsAffected()
+		}
+	}
+	return nil
+}
+
+func anonymize(tx *pgx.Tx, nickname string, result *models.DeleteResult) error {
+	tombstone := models.Tombstone
+	if _, err := tx.Exec(insertTombstoneQuery, tombstone.About, tombstone.Email, tombstone.FullName,
+		tombstone.Nickname); err != nil {
+		return err
+	}
+
+	if err := runSteps(tx, []step{
+		{anonymizeForumsQuery, &result.Forums},
+		{anonymizeThreadsQuery, &result.Threads},
+		{anonymizePostsQuery, &result.Posts},
+		{anonymizeUsersForumQuery, nil},
+	}, nickname, tombstone.Nickname); err != nil {
+		return err
+	}
+
+	// The user owns no forum any more, so this only removes their own rows.
+	return runSteps(tx, []step{
+		{deleteUserVotesQuery, &result.Votes},
+		{deleteParticipantQuery, nil},
+	}, nickname)
+}
+
+// collectSlugs runs an UPDATE ... RETURNING slug and adds the slugs to seen.
+func collectSlugs(tx *pgx.Tx, query, nickname string, seen map[string]bool) error {
+	rows, err := tx.Query(query, nickname)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return err
+		}
+		seen[slug] = true
+	}
+	return rows.Err()
+}
+
+func cascade(tx *pgx.Tx, nickname string, result *models.DeleteResult) error {
+	if err := runSteps(tx, []step{{lockForumsQuery, nil}, {lockThreadsQuery, nil}, {lockPostsQuery, nil}},
+		nickname); err != nil {
+		return err
+	}
+
+	affected := make(map[string]bool)
+	for _, query := range []string{subtractForumPostsQuery, subtractForumThreadsQuery} {
+		if err := collectSlugs(tx, query, nickname, affected); err != nil {
+			return err
+		}
+	}
+
+	if err := runSteps(tx, []step{
+		{subtractThreadVotesQuery, nil},
+		{deleteCascadedVotesQuery, &result.Votes},
+		{deleteCascadedPostsQuery, &result.Posts},
+		{deleteCascadedThreadsQuery, &result.Threads},
+	}, nickname); err != nil {
+		return err
+	}
+
+	slugs := make([]string, 0, len(affected))
+	for slug := range affected {
+		slugs = append(slugs, slug)
+	}
+	if _, err := tx.Exec(deleteIdleParticipantsQuery, slugs); err != nil {
+		return err
+	}
+
+	return runSteps(tx, []step{
+		{deleteParticipantQuery, nil},
+		{deleteOwnedForumsQuery, &result.Forums},
+	}, nickname)
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	forumModels "DbProjectForum/internal/app/forum/models"
+	"DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/requestctx"
+	"context"
+	"github.com/go-openapi/strfmt"
+	"github.com/jackc/pgx"
+	"io"
+	"time"
+)
+
+const (
+	exportUserQuery = `SELECT about, email, fullname, nickname FROM users WHERE nickname = $1`
+
+	exportForumsQuery = `SELECT forum."user", forum.posts, forum.slug, forum.threads, forum.title
+FROM users_forum
+         JOIN forum ON forum.slug = users_forum.slug
+WHERE users_forum.nickname = $1
+ORDER BY forum.slug`
+
+	exportThreadsQuery = `SELECT author, created, forum, id, message, slug, title, votes
+FROM thread WHERE author = $1 ORDER BY id`
+
+	// Deleted posts are exported with their messages, which are still stored.
+	exportPostsQuery = `SELECT author, created, forum, id, isEdited, isDeleted, message, parent, thread
+FROM post WHERE author = $1 ORDER BY id`
+
+	exportVotesQuery = `SELECT idThread, voice FROM vote WHERE nickname = $1 ORDER BY idThread`
+)
+
+// exportRows writes one element of the current section per row of query.
+func exportRows(tx *pgx.Tx, e *exportWriter, query, nickname string,
+	scan func(rows *pgx.Rows) (interface{}, error)) error {
+	rows, err := tx.Query(query, nickname)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := e.item(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Export reads from a single snapshot and writes rows as they arrive.
+func (p *postgresUserRepository) Export(ctx context.Context, nickname string, w io.Writer) error {
+	ctx, cancel := requestctx.Detach(ctx)
+	defer cancel()
+
+	tx, err := p.Conn.BeginEx(ctx, &pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return apperrors.FromPg(err)
+	}
+	defer tx.Rollback()
+
+	var userObj models.User
+	err = tx.QueryRow(exportUserQuery, nickname).Scan(&userObj.About, &userObj.Email, &userObj.FullName,
+		&userObj.Nickname)
+	if err == pgx.ErrNoRows {
+		return apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	if err != nil {
+		return apperrors.FromPg(err)
+	}
+
+	e := newExportWriter(w, userObj)
+	sections := []struct {
+		name  string
+		query string
+		scan  func(rows *pgx.Rows) (interface{}, error)
+	}{
+		{exportForums, exportForumsQuery, func(rows *pgx.Rows) (interface{}, error) {
+			var forum forumModels.Forum
+			err := rows.Scan(&forum.User, &forum.Posts, &forum.Slug, &forum.Threads, &forum.Title)
+			return forum, err
+		}},
+		{exportThreads, exportThreadsQuery, func(rows *pgx.Rows) (interface{}, error) {
+			var thread forumModels.Thread
+			var created time.Time
+			err := rows.Scan(&thread.Author, &created, &thread.Forum, &thread.Id, &thread.Message,
+				&thread.Slug, &thread.Title, &thread.Votes)
+			thread.Created = strfmt.DateTime(created.UTC()).String()
+			return thread, err
+		}},
+		{exportPosts, exportPostsQuery, func(rows *pgx.Rows) (interface{}, error) {
+			var post forumModels.Post
+			var created time.Time
+			err := rows.Scan(&post.Author, &created, &post.Forum, &post.Id, &post.IsEdited, &post.IsDeleted,
+				&post.Message, &post.Parent, &post.Thread)
+			post.Created = strfmt.DateTime(created.UTC()).String()
+			return post, err
+		}},
+		{exportVotes, exportVotesQuery, func(rows *pgx.Rows) (interface{}, error) {
+			var vote models.ExportVote
+			err := rows.Scan(&vote.Thread, &vote.Voice)
+			return vote, err
+		}},
+	}
+
+	for _, section := range sections {
+		e.section(section.name)
+		if err := exportRows(tx, e, section.query, nickname, section.scan); err != nil {
+			return apperrors.FromPg(err)
+		}
+	}
+	return e.close()
+}
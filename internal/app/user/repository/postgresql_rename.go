@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"DbProjectForum/internal/app/user/models"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/requestctx"
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+	"strings"
+)
+
+// The foreign keys on the nickname cascade updates, so renaming the users row
+// renames the user in forums, threads, posts, votes, users_forum and the
+// aliases that point to them.
+const (
+	renameUserQuery = `UPDATE users SET nickname = $2 WHERE nickname = $1
+RETURNING about, email, fullname, nickname`
+
+	deleteAliasQuery = `DELETE FROM user_alias WHERE alias = $1`
+
+	insertAliasQuery = `INSERT INTO user_alias(alias, nickname) VALUES ($1, $2)
+ON CONFLICT (alias) DO UPDATE SET nickname = excluded.nickname`
+
+	userByAliasQuery = `SELECT users.about, users.email, users.fullname, users.nickname
+FROM user_alias
+         JOIN users ON users.nickname = user_alias.nickname
+WHERE user_alias.alias = $1`
+)
+
+func (p *postgresUserRepository) Rename(ctx context.Context, nickname, newNickname string) (models.User, error) {
+	ctx, cancel := requestctx.Detach(ctx)
+	defer cancel()
+
+	tx, err := p.Conn.BeginEx(ctx, nil)
+	if err != nil {
+		return models.User{}, apperrors.FromPg(err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRow(lockUserQuery, nickname).Scan(&current)
+	if err == pgx.ErrNoRows {
+		return models.User{}, apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	if err != nil {
+		return models.User{}, apperrors.FromPg(err)
+	}
+
+	// The new nickname may be a former one of this or another user; a user
+	// that owns it now takes precedence over the alias.
+	if _, err := tx.Exec(deleteAliasQuery, newNickname); err != nil {
+		return models.User{}, apperrors.FromPg(err)
+	}
+
+	var userObj models.User
+	err = tx.QueryRow(renameUserQuery, current, newNickname).Scan(&userObj.About, &userObj.Email,
+		&userObj.FullName, &userObj.Nickname)
+	if errors.Is(apperrors.FromPg(err), apperrors.ErrConflict) {
+		return models.User{}, apperrors.Conflict("Nickname %s is already taken", newNickname)
+	}
+	if err != nil {
+		return models.User{}, apperrors.FromPg(err)
+	}
+
+	// When only the case changed, the old nickname still finds the user.
+	if !strings.EqualFold(current, newNickname) {
+		if _, err := tx.Exec(insertAliasQuery, current, userObj.Nickname); err != nil {
+			return models.User{}, apperrors.FromPg(err)
+		}
+	}
+	return userObj, apperrors.FromPg(tx.Commit())
+}
+
+func (p *postgresUserRepository) GetByAlias(ctx context.Context, alias string) (models.User, error) {
+	var userObj models.User
+	err := p.Conn.QueryRow(userByAliasQuery, alias).Scan(&userObj.About, &userObj.Email, &userObj.FullName,
+		&userObj.Nickname)
+	if err == pgx.ErrNoRows {
+		return models.User{}, apperrors.NotFound("Can't find user by alias: %s", alias)
+	}
+	return userObj, apperrors.FromPg(err)
+}
@@ -3,41 +3,54 @@ package repository
 import (
 	"DbProjectForum/internal/app/user"
 	"DbProjectForum/internal/app/user/models"
-	"fmt"
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/sqlbuilder"
+	"context"
+	"errors"
 	"github.com/jackc/pgx"
+	"strings"
 )
 
 type postgresUserRepository struct {
-	Conn *pgx.ConnPool
+	Conn     *pgx.ConnPool
+	prepared bool
 }
 
-func NewPostgresCafeRepository(conn *pgx.ConnPool) user.Repository {
+// NewPostgresCafeRepository creates the user repository. When prepared is set,
+// queries run through the statements registered by PrepareStatements.
+func NewPostgresCafeRepository(conn *pgx.ConnPool, prepared bool) user.Repository {
 	return &postgresUserRepository{
-		Conn: conn,
+		Conn:     conn,
+		prepared: prepared,
 	}
 }
 
-func (p *postgresUserRepository) Add(user models.User) error {
-	query := `INSERT INTO users(
-    about,
-    email,
-    fullname,
-    nickname)
-	VALUES ($1, $2, $3, $4)`
+func (p *postgresUserRepository) statement(name string) string {
+	if p.prepared {
+		return name
+	}
+	return statements[name]
+}
+
+func (p *postgresUserRepository) Add(ctx context.Context, user models.User) error {
+	query := p.statement(insertUser)
 
-	_, err := p.Conn.Exec(query, user.About, user.Email, user.FullName, user.Nickname)
-	return err
+	_, err := p.Conn.Exec(query, user.About, user.Email, user.FullName, user.Nickname, user.PasswordHash)
+	if errors.Is(apperrors.FromPg(err), apperrors.ErrConflict) {
+		return apperrors.Conflict("User with nickname %s or email %s already exists", user.Nickname, user.Email)
+	}
+	return apperrors.FromPg(err)
 }
 
-func (p *postgresUserRepository) GetByNickAndEmail(nickname, email string) ([]models.User, error) {
-	query := `SELECT * FROM users WHERE LOWER(Nickname)=LOWER($1) OR Email=$2`
+func (p *postgresUserRepository) GetByNickAndEmail(ctx context.Context, nickname, email string) ([]models.User, error) {
+	query := p.statement(usersByNickOrEmail)
 
 	var data []models.User
 
 	row, err := p.Conn.Query(query, nickname, email)
 
 	if err != nil {
-		return nil, err
+		return nil, apperrors.FromPg(err)
 	}
 
 	defer func() {
@@ -53,60 +66,92 @@ func (p *postgresUserRepository) GetByNickAndEmail(nickname, email string) ([]mo
 		err = row.Scan(&u.About, &u.Email, &u.FullName, &u.Nickname)
 
 		if err != nil {
-			return nil, err
+			return nil, apperrors.FromPg(err)
 		}
 
 		data = append(data, u)
 	}
 
-	return data, err
+	return data, apperrors.FromPg(row.Err())
 }
 
-func (p *postgresUserRepository) GetByNick(nickname string) (models.User, error) {
-	query := `SELECT * FROM users WHERE LOWER(Nickname)=LOWER($1)`
+func (p *postgresUserRepository) GetByNick(ctx context.Context, nickname string) (models.User, error) {
+	query := p.statement(userByNick)
 
 	var userObj models.User
-	err := p.Conn.QueryRow(query, nickname).Scan(&userObj.About, &userObj.Email, &userObj.FullName, &userObj.Nickname)
-	return userObj, err
+	var role string
+	err := p.Conn.QueryRow(query, nickname).Scan(&userObj.About, &userObj.Email, &userObj.FullName, &userObj.Nickname,
+		&userObj.PasswordHash, &role)
+	if err == pgx.ErrNoRows {
+		return userObj, apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	userObj.Role = models.Role(role)
+	return userObj, apperrors.FromPg(err)
+}
+
+func (p *postgresUserRepository) SetRole(ctx context.Context, nickname string, role models.Role) (models.User, error) {
+	var userObj models.User
+	var stored string
+	err := p.Conn.QueryRow(`UPDATE users SET role = $2 WHERE nickname = $1
+RETURNING about, email, fullname, nickname, role`, nickname, string(role)).
+		Scan(&userObj.About, &userObj.Email, &userObj.FullName, &userObj.Nickname, &stored)
+	if err == pgx.ErrNoRows {
+		return userObj, apperrors.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	userObj.Role = models.Role(stored)
+	return userObj, apperrors.FromPg(err)
+}
+
+func (p *postgresUserRepository) GetModerators(ctx context.Context, slug string) ([]models.User, error) {
+	rows, err := p.Conn.Query(`SELECT users.about, users.email, users.fullname, users.nickname FROM users
+    JOIN forum_moderator fm ON fm.nickname = users.nickname
+WHERE fm.slug = $1
+ORDER BY users.nickname`, slug)
+	if err != nil {
+		return nil, apperrors.FromPg(err)
+	}
+	defer rows.Close()
+
+	data := make([]models.User, 0)
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.About, &u.Email, &u.FullName, &u.Nickname); err != nil {
+			return nil, apperrors.FromPg(err)
+		}
+		data = append(data, u)
+	}
+	return data, apperrors.FromPg(rows.Err())
 }
 
-func (p *postgresUserRepository) Update(user models.User) (models.User, error) {
-	query := `UPDATE users SET 
-                 about=COALESCE(NULLIF($1, ''), about),
-                 email=COALESCE(NULLIF($2, ''), email),
-                 fullname=COALESCE(NULLIF($3, ''), fullname) 
-	WHERE LOWER(nickname) = LOWER($4) RETURNING *`
+func (p *postgresUserRepository) Update(ctx context.Context, user models.User) (models.User, error) {
+	query := p.statement(updateUser)
 
 	var userObj models.User
 	err := p.Conn.QueryRow(query, user.About, user.Email, user.FullName, user.Nickname).Scan(&userObj.About, &userObj.Email, &userObj.FullName, &userObj.Nickname)
-	return userObj, err
+	switch {
+	case err == pgx.ErrNoRows:
+		return userObj, apperrors.NotFound("Can't find user by nickname: %s", user.Nickname)
+	case errors.Is(apperrors.FromPg(err), apperrors.ErrConflict):
+		return userObj, apperrors.Conflict("This email is already registered by user: %s", user.Email)
+	}
+	return userObj, apperrors.FromPg(err)
 }
 
-func (p *postgresUserRepository) GetUsersByForum(slug string, limit int, since string, desc bool) ([]models.User, error) {
-	var query string
-	if desc {
-		if since != "" {
-			query = fmt.Sprintf(`SELECT users.about, users.Email, users.FullName, users.Nickname FROM users
-    	inner join users_forum uf on users.Nickname = uf.nickname
-        WHERE uf.slug =$1 AND uf.nickname < '%s'
-        ORDER BY lower(users.Nickname) DESC LIMIT NULLIF($2, 0)`, since)
-		} else {
-			query = `SELECT users.about, users.Email, users.FullName, users.Nickname FROM users
-    	inner join users_forum uf on users.Nickname = uf.nickname
-        WHERE uf.slug =$1
-        ORDER BY lower(users.Nickname) DESC LIMIT NULLIF($2, 0)`
-		}
-	} else {
-		query = fmt.Sprintf(`SELECT users.about, users.Email, users.FullName, users.Nickname FROM users
-    	inner join users_forum uf on users.Nickname = uf.nickname
-        WHERE uf.slug =$1 AND uf.nickname > '%s'
-        ORDER BY lower(users.Nickname) LIMIT NULLIF($2, 0)`, since)
+func (p *postgresUserRepository) GetUsersByForum(ctx context.Context, slug string, limit int, since string, desc bool) ([]models.User, error) {
+	selectUsers := sqlbuilder.NewSelect(`SELECT users.about, users.Email, users.FullName, users.Nickname FROM users
+    	inner join users_forum uf on users.Nickname = uf.nickname`).Where(`uf.slug = ?`, slug)
+	if since != "" && desc {
+		selectUsers.Where(`uf.nickname < ?`, since)
+	} else if since != "" {
+		selectUsers.Where(`uf.nickname > ?`, since)
 	}
+	query, args := selectUsers.OrderBy(`lower(users.Nickname)`, desc).Limit(limit).Build()
+
 	var data []models.User
-	row, err := p.Conn.Query(query, slug, limit)
+	row, err := p.Conn.Query(query, args...)
 
 	if err != nil {
-		return data, nil
+		return data, apperrors.FromPg(err)
 	}
 
 	defer func() {
@@ -122,11 +167,44 @@ func (p *postgresUserRepository) GetUsersByForum(slug string, limit int, since s
 		err = row.Scan(&u.About, &u.Email, &u.FullName, &u.Nickname)
 
 		if err != nil {
-			return data, err
+			return data, apperrors.FromPg(err)
 		}
 
 		data = append(data, u)
 	}
 
-	return data, err
+	return data, apperrors.FromPg(row.Err())
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (p *postgresUserRepository) GetUsers(ctx context.Context, prefix string, limit int, since string, desc bool) ([]models.User, error) {
+	selectUsers := sqlbuilder.NewSelect(`SELECT about, email, fullname, nickname FROM users`)
+	if prefix != "" {
+		// citext makes LIKE on nickname case-insensitive; fullname is plain text.
+		pattern := likeEscaper.Replace(prefix) + "%"
+		selectUsers.Where(`(nickname LIKE ? OR fullname ILIKE ?)`, pattern, pattern)
+	}
+	if since != "" && desc {
+		selectUsers.Where(`nickname < ?`, since)
+	} else if since != "" {
+		selectUsers.Where(`nickname > ?`, since)
+	}
+	query, args := selectUsers.OrderBy(`nickname`, desc).Limit(limit).Build()
+
+	row, err := p.Conn.Query(query, args...)
+	if err != nil {
+		return nil, apperrors.FromPg(err)
+	}
+	defer row.Close()
+
+	var data []models.User
+	for row.Next() {
+		var u models.User
+		if err := row.Scan(&u.About, &u.Email, &u.FullName, &u.Nickname); err != nil {
+			return nil, apperrors.FromPg(err)
+		}
+		data = append(data, u)
+	}
+	return data, apperrors.FromPg(row.Err())
 }
@@ -0,0 +1,39 @@
+package repository
+
+import "github.com/jackc/pgx"
+
+const (
+	insertUser         = "insert_user"
+	usersByNickOrEmail = "users_by_nick_or_email"
+	userByNick         = "user_by_nick"
+	updateUser         = "update_user"
+)
+
+var statements = map[string]string{
+	insertUser: `INSERT INTO users(
+    about,
+    email,
+    fullname,
+    nickname,
+    password_hash)
+	VALUES ($1, $2, $3, $4, NULLIF($5, ''))`,
+	usersByNickOrEmail: `SELECT about, email, fullname, nickname FROM users WHERE LOWER(Nickname)=LOWER($1) OR Email=$2`,
+	userByNick: `SELECT about, email, fullname, nickname, COALESCE(password_hash, ''), role FROM users
+	WHERE LOWER(Nickname)=LOWER($1)`,
+	updateUser: `UPDATE users SET
+                 about=COALESCE(NULLIF($1, ''), about),
+                 email=COALESCE(NULLIF($2, ''), email),
+                 fullname=COALESCE(NULLIF($3, ''), fullname)
+	WHERE LOWER(nickname) = LOWER($4) RETURNING about, email, fullname, nickname`,
+}
+
+// PrepareStatements registers the hot queries of the user repository on a
+// new pool connection. It is meant to be called from ConnPoolConfig.AfterConnect.
+func PrepareStatements(conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(name, sql); err != nil {
+			return err
+		}
+	}
+	return nil
+}
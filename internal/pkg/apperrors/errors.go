@@ -0,0 +1,92 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx"
+)
+
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrConflict            = errors.New("already exists")
+	ErrParentInOtherThread = errors.New("parent post was created in another thread")
+	ErrValidation          = errors.New("validation failed")
+	ErrUnauthorized        = errors.New("authentication required")
+	ErrForbidden           = errors.New("forbidden")
+)
+
+// Error carries a client-facing message together with one of the sentinel
+// kinds above, which errors.Is matches against.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func New(kind error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+func NotFound(format string, args ...interface{}) error {
+	return New(ErrNotFound, format, args...)
+}
+
+func Conflict(format string, args ...interface{}) error {
+	return New(ErrConflict, format, args...)
+}
+
+func Validation(format string, args ...interface{}) error {
+	return New(ErrValidation, format, args...)
+}
+
+func Unauthorized(format string, args ...interface{}) error {
+	return New(ErrUnauthorized, format, args...)
+}
+
+func Forbidden(format string, args ...interface{}) error {
+	return New(ErrForbidden, format, args...)
+}
+
+// Message returns the client-facing text of err, falling back to the text
+// of its kind.
+func Message(err error) string {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Message
+	}
+	return err.Error()
+}
+
+// FromPg translates pgx errors into domain errors. Errors it does not
+// recognise are returned unchanged and treated as internal errors.
+func FromPg(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == pgx.ErrNoRows {
+		return ErrNotFound
+	}
+
+	pgErr, ok := err.(pgx.PgError)
+	if !ok {
+		return err
+	}
+	switch pgErr.Code {
+	case "23505":
+		return ErrConflict
+	case "23503":
+		return ErrNotFound
+	case "00409":
+		return ErrParentInOtherThread
+	case "23502", "23514", "22001", "22007", "22008", "22P02":
+		return ErrValidation
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+// Package identity carries the authenticated user of a request.
+package identity
+
+import (
+	userModels "DbProjectForum/internal/app/user/models"
+	"context"
+)
+
+// Key is the fasthttp user value holding the nickname of the authenticated
+// user. It differs from the "nickname" route parameter, which shares the
+// user values of the request.
+const Key = "identity"
+
+// RoleKey is the fasthttp user value holding the site role of the
+// authenticated user.
+const RoleKey = "identity_role"
+
+// FromContext returns the nickname of the authenticated user, or an empty
+// string for anonymous requests.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	nickname, _ := ctx.Value(Key).(string)
+	return nickname
+}
+
+// Role returns the site role of the authenticated user, or an empty role for
+// anonymous requests.
+func Role(ctx context.Context) userModels.Role {
+	if ctx == nil {
+		return ""
+	}
+	role, _ := ctx.Value(RoleKey).(userModels.Role)
+	return role
+}
+
+func IsAdmin(ctx context.Context) bool {
+	return Role(ctx) == userModels.RoleAdmin
+}
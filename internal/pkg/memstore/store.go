@@ -0,0 +1,162 @@
+package memstore
+
+import (
+	authModels "DbProjectForum/internal/app/auth/models"
+	forumModels "DbProjectForum/internal/app/forum/models"
+	userModels "DbProjectForum/internal/app/user/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Thread is a stored thread together with its creation time, which the
+// model only carries as formatted text.
+type Thread struct {
+	forumModels.Thread
+	CreatedAt time.Time
+}
+
+// Post is a stored post with its materialized path, mirroring post.path.
+type Post struct {
+	forumModels.Post
+	CreatedAt time.Time
+	PathIDs   []int64
+}
+
+type VoteKey struct {
+	Nickname string
+	Thread   int64
+}
+
+// Store keeps the tables shared by the in-memory repositories. Keys of the
+// maps are folded with Key to follow the citext semantics of the schema.
+type Store struct {
+	sync.RWMutex
+
+	Users  map[string]*userModels.User
+	Forums map[string]*forumModels.Forum
+	// Threads and Posts are indexed by id - 1. Deleted rows leave a nil slot
+	// so that ids are never reused.
+	Threads []*Thread
+	Posts   []*Post
+	Votes   map[VoteKey]int32
+	// UsersForum maps a forum key to the nickname keys of its participants.
+	UsersForum map[string]map[string]bool
+	// Moderators maps a forum key to the nickname keys of its moderators,
+	// not counting the owner.
+	Moderators map[string]map[string]bool
+	// Aliases maps former nickname keys of renamed users to their current
+	// nickname keys.
+	Aliases map[string]string
+	// Sessions maps token hashes to login sessions.
+	Sessions map[string]authModels.Session
+	// APITokens maps token hashes to API tokens; APITokenID is the id of
+	// the latest one.
+	APITokens  map[string]authModels.APIToken
+	APITokenID int64
+}
+
+func New() *Store {
+	s := &Store{}
+	s.Reset()
+	return s
+}
+
+// Reset drops all data. The caller must hold the write lock when the store
+// is shared.
+func (s *Store) Reset() {
+	s.Users = make(map[string]*userModels.User)
+	s.Forums = make(map[string]*forumModels.Forum)
+	s.Threads = nil
+	s.Posts = nil
+	s.Votes = make(map[VoteKey]int32)
+	s.UsersForum = make(map[string]map[string]bool)
+	s.Moderators = make(map[string]map[string]bool)
+	s.Aliases = make(map[string]string)
+	s.Sessions = make(map[string]authModels.Session)
+	s.APITokens = make(map[string]authModels.APIToken)
+	s.APITokenID = 0
+}
+
+// Key folds case the way citext compares values.
+func Key(value string) string {
+	return strings.ToLower(Copy(value))
+}
+
+// Copy detaches value from the buffer it points into. Route parameters share
+// memory with the request, which fasthttp reuses once the handler returns.
+func Copy(value string) string {
+	return string([]byte(value))
+}
+
+func (s *Store) Thread(id int) (*Thread, bool) {
+	if id <= 0 || id > len(s.Threads) {
+		return nil, false
+	}
+	thread := s.Threads[id-1]
+	return thread, thread != nil
+}
+
+func (s *Store) ThreadBySlug(slug string) (*Thread, bool) {
+	key := Key(slug)
+	for _, thread := range s.Threads {
+		if thread != nil && thread.Slug.Valid && Key(thread.Slug.String) == key {
+			return thread, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Store) Post(id int64) (*Post, bool) {
+	if id <= 0 || id > int64(len(s.Posts)) {
+		return nil, false
+	}
+	post := s.Posts[id-1]
+	return post, post != nil
+}
+
+// DeleteThread removes the thread without touching its posts, votes or the
+// counters that refer to it.
+func (s *Store) DeleteThread(id int) {
+	if _, ok := s.Thread(id); ok {
+		s.Threads[id-1] = nil
+	}
+}
+
+// DeletePost removes the post without touching its replies or the counters
+// that refer to it.
+func (s *Store) DeletePost(id int64) {
+	if _, ok := s.Post(id); ok {
+		s.Posts[id-1] = nil
+	}
+}
+
+// ThreadCount returns the number of threads that are not deleted.
+func (s *Store) ThreadCount() int {
+	count := 0
+	for _, thread := range s.Threads {
+		if thread != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// PostCount returns the number of posts that are not deleted.
+func (s *Store) PostCount() int {
+	count := 0
+	for _, post := range s.Posts {
+		if post != nil && !post.IsDeleted {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Store) AddUserToForum(nickname, forumSlug string) {
+	forumKey := Key(forumSlug)
+	if s.UsersForum[forumKey] == nil {
+		s.UsersForum[forumKey] = make(map[string]bool)
+	}
+	s.UsersForum[forumKey][Key(nickname)] = true
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"DbProjectForum/internal/pkg/apperrors"
+	"errors"
+	"github.com/jackc/pgx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+const namespace = "forum"
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	RepositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "call_duration_seconds",
+		Help:      "Latency of repository calls by method and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"repository", "method", "outcome"})
+
+	ReconcileRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "reconciler",
+		Name:      "runs_total",
+		Help:      "Number of counter reconciliation passes by counter and outcome.",
+	}, []string{"counter", "outcome"})
+
+	ReconciledRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "reconciler",
+		Name:      "corrected_rows_total",
+		Help:      "Number of rows whose denormalized counters were corrected.",
+	}, []string{"counter"})
+)
+
+func init() {
+	prometheus.MustRegister(HTTPRequests, HTTPDuration, RepositoryDuration, ReconcileRuns, ReconciledRows)
+}
+
+// RegisterPool exposes the connection counts reported by pool.Stat().
+func RegisterPool(pool *pgx.ConnPool) {
+	gauge := func(name, help string, value func(stat pgx.ConnPoolStat) int) prometheus.GaugeFunc {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "pgx_pool",
+			Name:      name,
+			Help:      help,
+		}, func() float64 {
+			return float64(value(pool.Stat()))
+		})
+	}
+
+	prometheus.MustRegister(
+		gauge("current_connections", "Live connections in the pool.", func(stat pgx.ConnPoolStat) int {
+			return stat.CurrentConnections
+		}),
+		gauge("available_connections", "Idle connections in the pool.", func(stat pgx.ConnPoolStat) int {
+			return stat.AvailableConnections
+		}),
+		gauge("max_connections", "Maximum size of the pool.", func(stat pgx.ConnPoolStat) int {
+			return stat.MaxConnections
+		}),
+	)
+}
+
+func Handler() fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
+}
+
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, apperrors.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, apperrors.ErrConflict), errors.Is(err, apperrors.ErrParentInOtherThread):
+		return "conflict"
+	case errors.Is(err, apperrors.ErrValidation):
+		return "invalid"
+	case errors.Is(err, apperrors.ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, apperrors.ErrForbidden):
+		return "forbidden"
+	default:
+		return "error"
+	}
+}
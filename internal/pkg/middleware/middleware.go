@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"DbProjectForum/internal/pkg/metrics"
+	"DbProjectForum/internal/pkg/requestid"
+	"DbProjectForum/internal/pkg/responses"
+	"fmt"
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"time"
+)
+
+type Middleware func(next fasthttp.RequestHandler) fasthttp.RequestHandler
+
+// Chain wraps handler so that the first middleware runs first.
+func Chain(handler fasthttp.RequestHandler, middlewares ...Middleware) fasthttp.RequestHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+func ApplicationJSON(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.Response.Header.Set("Content-Type", "application/json")
+		next(ctx)
+	}
+}
+
+// Recover turns a panic in next into a logged 500 response instead of
+// dropping the connection.
+func Recover(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				responses.SendServerError(fmt.Sprintf("panic: %v", rec), ctx)
+			}
+		}()
+		next(ctx)
+	}
+}
+
+func RequestID(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		id := string(ctx.Request.Header.Peek(requestid.Header))
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		ctx.SetUserValue(requestid.Key, id)
+		ctx.Response.Header.Set(requestid.Header, id)
+		next(ctx)
+	}
+}
+
+// Logging writes one JSON line per request. The route is the pattern the
+// router matched, so it requires Router.SaveMatchedRoutePath.
+func Logging(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		next(ctx)
+
+		logger := requestid.Logger(ctx)
+		logger.Info().
+			Str("method", string(ctx.Method())).
+			Str("route", Route(ctx)).
+			Str("path", string(ctx.Path())).
+			Int("status", ctx.Response.StatusCode()).
+			Dur("latency", time.Since(start)).
+			Int("size", len(ctx.Response.Body())).
+			Msg("request")
+	}
+}
+
+// Route returns the pattern of the matched route, or an empty string when
+// no route matched.
+func Route(ctx *fasthttp.RequestCtx) string {
+	route, _ := ctx.UserValue(router.MatchedRoutePathParam).(string)
+	return route
+}
+
+func Metrics(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		next(ctx)
+
+		route := Route(ctx)
+		if route == "" {
+			route = "unmatched"
+		}
+		method := string(ctx.Method())
+
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(ctx.Response.StatusCode())).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
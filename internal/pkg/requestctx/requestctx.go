@@ -0,0 +1,38 @@
+// Package requestctx gives repositories a request context that outlives the
+// start of a server shutdown.
+package requestctx
+
+import (
+	"context"
+	"time"
+)
+
+// Detach returns a context with the values and the deadline of ctx but
+// without its cancellation. fasthttp cancels every RequestCtx as soon as
+// Server.Shutdown starts, while Shutdown itself waits for in-flight requests
+// to finish, so work that should drain, like an open transaction, must not
+// listen to it.
+func Detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.Context(valuesOnly{ctx})
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	return context.WithCancel(detached)
+}
+
+// valuesOnly keeps the values of a context and drops everything else.
+type valuesOnly struct {
+	context.Context
+}
+
+func (valuesOnly) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (valuesOnly) Done() <-chan struct{} {
+	return nil
+}
+
+func (valuesOnly) Err() error {
+	return nil
+}
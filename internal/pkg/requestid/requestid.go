@@ -0,0 +1,51 @@
+package requestid
+
+import (
+	"context"
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	Header = "X-Request-ID"
+
+	// Key is the fasthttp user value holding the request ID. It is a plain
+	// string so that RequestCtx.Value can resolve it.
+	Key = "request_id"
+
+	maxLength = 128
+)
+
+func New() string {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+// Valid reports whether an ID supplied by a client is safe to propagate.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(Key).(string)
+	return id
+}
+
+func Logger(ctx context.Context) zerolog.Logger {
+	return log.With().Str(Key, FromContext(ctx)).Logger()
+}
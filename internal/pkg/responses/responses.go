@@ -1,16 +1,33 @@
 package responses
 
 import (
+	"DbProjectForum/internal/pkg/apperrors"
+	"DbProjectForum/internal/pkg/requestid"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/valyala/fasthttp"
 	"net/http"
+	"runtime/debug"
 )
 
+const serverErrorMessage = "internal server error"
+
+// SendServerError logs errorMessage with a stack trace and answers with a
+// generic 500 body, so that database and runtime details never reach the
+// client. The error ID in the body matches the log entry.
 func SendServerError(errorMessage string, ctx *fasthttp.RequestCtx) {
+	errorID := requestid.New()
+
+	logger := requestid.Logger(ctx)
+	logger.Error().
+		Str("error_id", errorID).
+		Str("stack", string(debug.Stack())).
+		Msg(errorMessage)
+
+	body, _ := json.Marshal(HttpError{Message: serverErrorMessage, ErrorID: errorID})
+	ctx.ResetBody()
 	ctx.SetStatusCode(http.StatusInternalServerError)
-	fmt.Printf("{level: error, message: %s}", errors.New(errorMessage))
+	ctx.SetBody(body)
 }
 
 func SendResponse(code int, data interface{}, ctx *fasthttp.RequestCtx) {
@@ -27,3 +44,26 @@ func SendResponse(code int, data interface{}, ctx *fasthttp.RequestCtx) {
 func SendResponseOK(data interface{}, ctx *fasthttp.RequestCtx) {
 	SendResponse(200, data, ctx)
 }
+
+// SendError answers with the HTTP status matching the domain error kind of
+// err. Errors without a kind are reported as internal server errors.
+func SendError(err error, ctx *fasthttp.RequestCtx) {
+	var code int
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, apperrors.ErrConflict), errors.Is(err, apperrors.ErrParentInOtherThread):
+		code = http.StatusConflict
+	case errors.Is(err, apperrors.ErrValidation):
+		code = http.StatusBadRequest
+	case errors.Is(err, apperrors.ErrUnauthorized):
+		code = http.StatusUnauthorized
+	case errors.Is(err, apperrors.ErrForbidden):
+		code = http.StatusForbidden
+	default:
+		SendServerError(err.Error(), ctx)
+		return
+	}
+
+	SendResponse(code, HttpError{Message: apperrors.Message(err)}, ctx)
+}
@@ -0,0 +1,103 @@
+package sqlbuilder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Select composes a SELECT statement from fragments that use "?" as the
+// argument placeholder. Values are always passed as arguments; Build turns
+// the placeholders into positional $n parameters, see Positional.
+type Select struct {
+	base  string
+	where []string
+	order []string
+	limit string
+	args  []interface{}
+}
+
+func NewSelect(base string, args ...interface{}) *Select {
+	return &Select{base: base, args: args}
+}
+
+// Where adds a condition joined with AND to the previous ones.
+func (s *Select) Where(condition string, args ...interface{}) *Select {
+	s.where = append(s.where, condition)
+	s.args = append(s.args, args...)
+	return s
+}
+
+// OrderBy adds a sort expression. The expression is trusted SQL and must
+// never come from user input.
+func (s *Select) OrderBy(expression string, desc bool) *Select {
+	if desc {
+		expression += " DESC"
+	}
+	s.order = append(s.order, expression)
+	return s
+}
+
+// Limit restricts the number of rows; zero or a negative limit means no limit.
+func (s *Select) Limit(limit int) *Select {
+	if limit > 0 {
+		s.limit = " LIMIT ?"
+		s.args = append(s.args, limit)
+	}
+	return s
+}
+
+// Subquery returns the statement with "?" placeholders, to be embedded into
+// a condition of another Select together with its arguments.
+func (s *Select) Subquery() (string, []interface{}) {
+	var query strings.Builder
+	query.WriteString(s.base)
+	if len(s.where) != 0 {
+		query.WriteString(" WHERE ")
+		query.WriteString(strings.Join(s.where, " AND "))
+	}
+	if len(s.order) != 0 {
+		query.WriteString(" ORDER BY ")
+		query.WriteString(strings.Join(s.order, ", "))
+	}
+	query.WriteString(s.limit)
+	return query.String(), s.args
+}
+
+func (s *Select) Build() (string, []interface{}) {
+	query, args := s.Subquery()
+	return Positional(query), args
+}
+
+// Positional replaces every "?" placeholder in query with $1, $2 and so on.
+// Question marks inside single-quoted literals and double-quoted identifiers
+// are left alone, and "??" stands for a literal "?", which is how the jsonb
+// operators ?, ?| and ?& are written. Dollar-quoted strings, backslash escapes
+// in E-strings and comments are not recognised, so fragments must not contain them.
+func Positional(query string) string {
+	var result strings.Builder
+	n := 0
+	var quote rune
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			// A doubled quote inside a literal closes and reopens it, which
+			// leaves the text untouched either way.
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?' && i+1 < len(runes) && runes[i+1] == '?':
+			i++
+		case r == '?':
+			n++
+			result.WriteByte('$')
+			result.WriteString(strconv.Itoa(n))
+			continue
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
@@ -0,0 +1,91 @@
+package sqlbuilder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPositional(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"no placeholders", "SELECT 1", "SELECT 1"},
+		{"numbers in order", "a = ? AND b = ? LIMIT ?", "a = $1 AND b = $2 LIMIT $3"},
+		{"string literal", "a = '?' AND b = ?", "a = '?' AND b = $1"},
+		{"doubled quote in literal", "a = 'it''s ?' AND b = ?", "a = 'it''s ?' AND b = $1"},
+		{"quoted identifier", `SELECT "what?" FROM t WHERE a = ?`, `SELECT "what?" FROM t WHERE a = $1`},
+		{"jsonb operators", "data ?? 'key' AND data ??| ? AND data ??& ?",
+			"data ? 'key' AND data ?| $1 AND data ?& $2"},
+		{"non-ASCII text", "title = 'привет?' AND slug = ?", "title = 'привет?' AND slug = $1"},
+	}
+	for _, test := range tests {
+		if got := Positional(test.query); got != test.want {
+			t.Errorf("%s: Positional(%q) = %q, want %q", test.name, test.query, got, test.want)
+		}
+	}
+}
+
+// Hostile values never reach the query text: they only travel as arguments,
+// so quotes, placeholders and statement separators in them are inert.
+func TestHostileValuesStayArguments(t *testing.T) {
+	hostile := []string{
+		`'; DROP TABLE post; --`,
+		`x' OR '1'='1`,
+		`?`,
+		`$1`,
+		`"quoted"`,
+		`2006-01-02T15:04:05Z' OR created IS NOT NULL OR '`,
+	}
+	for _, value := range hostile {
+		slug, since := value, value
+		query, args := NewSelect(`SELECT slug FROM thread`).
+			Where(`LOWER(forum)=LOWER(?)`, slug).
+			Where(`created >= ?`, since).
+			OrderBy(`created`, true).
+			Limit(10).
+			Build()
+
+		want := `SELECT slug FROM thread WHERE LOWER(forum)=LOWER($1) AND created >= $2 ORDER BY created DESC LIMIT $3`
+		if query != want {
+			t.Errorf("value %q: query = %q, want %q", value, query, want)
+		}
+		if wantArgs := []interface{}{slug, since, 10}; !reflect.DeepEqual(args, wantArgs) {
+			t.Errorf("value %q: args = %#v, want %#v", value, args, wantArgs)
+		}
+	}
+}
+
+func TestSubqueryNumbersArgumentsOnce(t *testing.T) {
+	parents, parentArgs := NewSelect(`SELECT id FROM post`).
+		Where(`thread = ?`, 7).
+		Where(`path[1] > (SELECT path[1] FROM post WHERE id = ?)`, 3).
+		OrderBy(`id`, false).
+		Limit(2).
+		Subquery()
+	if strings.Contains(parents, "$") {
+		t.Fatalf("subquery %q already has positional parameters", parents)
+	}
+
+	query, args := NewSelect(`SELECT id FROM post`).Where(`path[1] IN (`+parents+`)`, parentArgs...).
+		OrderBy(`path`, false).
+		Build()
+
+	want := `SELECT id FROM post WHERE path[1] IN (SELECT id FROM post WHERE thread = $1 AND ` +
+		`path[1] > (SELECT path[1] FROM post WHERE id = $2) ORDER BY id LIMIT $3) ORDER BY path`
+	if query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+	if wantArgs := []interface{}{7, 3, 2}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", args, wantArgs)
+	}
+}
+
+func TestLimitZeroMeansNoLimit(t *testing.T) {
+	query, args := NewSelect(`SELECT id FROM post`).Limit(0).Build()
+	if query != `SELECT id FROM post` || len(args) != 0 {
+		t.Errorf("Build() = %q, %#v", query, args)
+	}
+}
@@ -0,0 +1,74 @@
+// Package testdb opens the storage backends for tests and benchmarks.
+//
+// Postgres only runs when FORUM_TEST_POSTGRES is set, because every test
+// empties the database that the usual FORUM_CONFIG and FORUM_DB_* settings
+// point to. Without it, Postgres tests are skipped.
+package testdb
+
+import (
+	"DbProjectForum/configs"
+	"DbProjectForum/internal/app/storage"
+	"context"
+	"os"
+	"testing"
+)
+
+// Env is the variable that allows tests to clear and use Postgres.
+const Env = "FORUM_TEST_POSTGRES"
+
+// Backend opens a fresh, empty set of repositories.
+type Backend struct {
+	Name string
+	Open func(tb testing.TB) storage.Repositories
+}
+
+// Backends lists the memory storage and Postgres, for suites that must behave
+// the same on both.
+var Backends = []Backend{
+	{Name: configs.StorageMemory, Open: Memory},
+	{Name: configs.StoragePostgres, Open: func(tb testing.TB) storage.Repositories {
+		return Postgres(tb, false)
+	}},
+}
+
+// Memory opens repositories on a new in-memory store.
+func Memory(tb testing.TB) storage.Repositories {
+	tb.Helper()
+	config := configs.Default()
+	config.Storage = configs.StorageMemory
+	return open(tb, config)
+}
+
+// Postgres opens repositories on the test database with all migrations
+// applied and every table empty. simple selects the simple protocol instead
+// of prepared statements.
+func Postgres(tb testing.TB, simple bool) storage.Repositories {
+	tb.Helper()
+	if os.Getenv(Env) == "" {
+		tb.Skipf("set %s to run against Postgres; the database gets cleared", Env)
+	}
+
+	config, err := configs.Load("test", nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	config.Storage = configs.StoragePostgres
+	config.Postgres.Migrate = true
+	config.Postgres.SimpleProtocol = simple
+
+	repos := open(tb, config)
+	if err := repos.Forum.ClearDatabase(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+	return repos
+}
+
+func open(tb testing.TB, config configs.Config) storage.Repositories {
+	tb.Helper()
+	repos, err := storage.Open(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(repos.Close)
+	return repos
+}